@@ -0,0 +1,179 @@
+package periskop
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sync"
+)
+
+// genericErrorTypes are the types of errors that only carry a message or wrap other errors,
+// so they say nothing about what failed
+var genericErrorTypes = map[string]bool{
+	"*errors.errorString":         true,
+	"*errors.joinError":           true,
+	"*fmt.wrapError":              true,
+	"*fmt.wrapErrors":             true,
+	"*errutils.Error":             true,
+	"*errors.fundamental":         true,
+	"*errors.withStack":           true,
+	"*errors.withMessage":         true,
+	"*errors.withMessageAndStack": true,
+}
+
+type errorClass struct {
+	target error
+	class  string
+}
+
+var (
+	errorClasses = []errorClass{
+		{io.EOF, "io.EOF"},
+		{io.ErrUnexpectedEOF, "io.ErrUnexpectedEOF"},
+		{context.Canceled, "context.Canceled"},
+		{context.DeadlineExceeded, "context.DeadlineExceeded"},
+		{os.ErrNotExist, "os.ErrNotExist"},
+		{os.ErrExist, "os.ErrExist"},
+		{os.ErrPermission, "os.ErrPermission"},
+	}
+	errorClassesMux sync.RWMutex
+)
+
+// RegisterErrorClass registers the class used to report errors matching `target` (according to errors.Is),
+// e.g. RegisterErrorClass(sql.ErrNoRows, "sql.ErrNoRows")
+func RegisterErrorClass(target error, class string) {
+	errorClassesMux.Lock()
+	defer errorClassesMux.Unlock()
+	errorClasses = append(errorClasses, errorClass{target, class})
+}
+
+// ErrorWithSeverity is implemented by errors that define the severity they are reported with.
+// It takes precedence over the severity given when reporting the error.
+type ErrorWithSeverity interface {
+	error
+	PeriskopSeverity() Severity
+}
+
+// ErrorWithKey is implemented by errors that define their own aggregation key.
+// An aggregation key given when reporting the error takes precedence over it.
+type ErrorWithKey interface {
+	error
+	PeriskopKey() string
+}
+
+// ErrorWithClass is implemented by errors that define the class they are reported with
+type ErrorWithClass interface {
+	error
+	PeriskopClass() string
+}
+
+// ErrorWithFields is implemented by errors that attach extra fields to their reports
+type ErrorWithFields interface {
+	error
+	PeriskopFields() map[string]interface{}
+}
+
+// getErrorSeverity gets the severity of the first error in the chain implementing ErrorWithSeverity,
+// falling back to `severity`
+func getErrorSeverity(err error, severity Severity) Severity {
+	var e ErrorWithSeverity
+	if errors.As(err, &e) {
+		if s := e.PeriskopSeverity(); s != "" {
+			return s
+		}
+	}
+	return severity
+}
+
+// getErrorKey gets the aggregation key of the first error in the chain implementing ErrorWithKey,
+// falling back to `errKey`
+func getErrorKey(err error, errKey string) string {
+	var e ErrorWithKey
+	if len(errKey) == 0 && errors.As(err, &e) {
+		return e.PeriskopKey()
+	}
+	return errKey
+}
+
+// getErrorClass gets the class of the error. In order of precedence, it's the class defined by the first
+// error in the chain implementing ErrorWithClass, the class registered for a sentinel error in the chain,
+// the innermost non generic type in the chain or the type of the error itself.
+func getErrorClass(err error) string {
+	var e ErrorWithClass
+	if errors.As(err, &e) {
+		if class := e.PeriskopClass(); class != "" {
+			return class
+		}
+	}
+	if class := getRegisteredErrorClass(err); class != "" {
+		return class
+	}
+	class := reflect.TypeOf(err).String()
+	for wrapped := errors.Unwrap(err); wrapped != nil; wrapped = errors.Unwrap(wrapped) {
+		if wrappedClass := reflect.TypeOf(wrapped).String(); !genericErrorTypes[wrappedClass] {
+			class = wrappedClass
+		}
+	}
+	return class
+}
+
+// getRegisteredErrorClass gets the class registered with RegisterErrorClass for the error,
+// or an empty string if there's none
+func getRegisteredErrorClass(err error) string {
+	errorClassesMux.RLock()
+	defer errorClassesMux.RUnlock()
+	for _, c := range errorClasses {
+		if errors.Is(err, c.target) {
+			return c.class
+		}
+	}
+	return ""
+}
+
+// getErrorFields merges the fields of all the errors in the chain implementing ErrorWithFields.
+// Outer errors take precedence over the errors they wrap.
+func getErrorFields(err error) map[string]interface{} {
+	var fields map[string]interface{}
+	for ; err != nil; err = errors.Unwrap(err) {
+		e, ok := err.(ErrorWithFields)
+		if !ok {
+			continue
+		}
+		for name, value := range e.PeriskopFields() {
+			if fields == nil {
+				fields = make(map[string]interface{})
+			}
+			if _, ok := fields[name]; !ok {
+				fields[name] = value
+			}
+		}
+	}
+	return fields
+}
+
+// sanitizeFields replaces the values of fields that can't be exported in JSON (like NaN, channels or
+// functions) with their fmt.Sprint representation, so a single field can't break the whole export.
+// The given map is not modified.
+func sanitizeFields(fields map[string]interface{}) map[string]interface{} {
+	var sanitized map[string]interface{}
+	for name, value := range fields {
+		if _, err := json.Marshal(value); err == nil {
+			continue
+		}
+		if sanitized == nil {
+			sanitized = make(map[string]interface{}, len(fields))
+			for name, value := range fields {
+				sanitized[name] = value
+			}
+		}
+		sanitized[name] = fmt.Sprint(value)
+	}
+	if sanitized == nil {
+		return fields
+	}
+	return sanitized
+}
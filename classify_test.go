@@ -0,0 +1,119 @@
+package periskop
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+)
+
+type paymentError struct {
+	orderID string
+}
+
+func (e paymentError) Error() string {
+	return "payment failed for order " + e.orderID
+}
+
+func (e paymentError) PeriskopSeverity() Severity {
+	return SeverityWarning
+}
+
+func (e paymentError) PeriskopKey() string {
+	return "payment-failed"
+}
+
+func (e paymentError) PeriskopClass() string {
+	return "PaymentError"
+}
+
+func (e paymentError) PeriskopFields() map[string]interface{} {
+	return map[string]interface{}{"order_id": e.orderID}
+}
+
+func TestClassify_wrappedError(t *testing.T) {
+	c := NewErrorCollector()
+	c.ReportError(fmt.Errorf("checkout: %w", paymentError{orderID: "42"}))
+
+	aggregatedErr, ok := c.aggregatedErrors["payment-failed"]
+	if !ok {
+		t.Fatalf("expected error aggregated with key defined by the error")
+	}
+	if aggregatedErr.Severity != SeverityWarning {
+		t.Errorf("incorrect severity, got %s", aggregatedErr.Severity)
+	}
+	errorWithContext := aggregatedErr.LatestErrors[0]
+	if errorWithContext.Error.Class != "PaymentError" {
+		t.Errorf("incorrect class name, got %s", errorWithContext.Error.Class)
+	}
+	if errorWithContext.Error.Message != "checkout: payment failed for order 42" {
+		t.Errorf("incorrect message, got %s", errorWithContext.Error.Message)
+	}
+	if errorWithContext.Fields["order_id"] != "42" {
+		t.Errorf("expected fields defined by the error, got %v", errorWithContext.Fields)
+	}
+}
+
+func TestClassify_explicitKey(t *testing.T) {
+	c := NewErrorCollector()
+	c.Report(ErrorReport{Err: paymentError{orderID: "42"}, ErrKey: "checkout"})
+
+	if _, ok := c.aggregatedErrors["checkout"]; !ok {
+		t.Errorf("expected reported key to take precedence over the error key")
+	}
+}
+
+type timeoutError struct{}
+
+func (e *timeoutError) Error() string {
+	return "timeout"
+}
+
+var errNoRows = errors.New("no rows in result set")
+
+func TestClassify_getErrorClass(t *testing.T) {
+	RegisterErrorClass(errNoRows, "sql.ErrNoRows")
+
+	cases := []struct {
+		expectedClass string
+		err           error
+	}{
+		{"*errors.errorString", errors.New("testing")},
+		{"*periskop.timeoutError", fmt.Errorf("query: %w", &timeoutError{})},
+		{"*periskop.timeoutError", fmt.Errorf("handler: %w", fmt.Errorf("query: %w", &timeoutError{}))},
+		{"io.EOF", fmt.Errorf("reading body: %w", io.EOF)},
+		{"sql.ErrNoRows", fmt.Errorf("getting user: %w", errNoRows)},
+		{"PaymentError", fmt.Errorf("checkout: %w", paymentError{})},
+	}
+	for _, tt := range cases {
+		t.Run(tt.expectedClass, func(t *testing.T) {
+			if class := getErrorClass(tt.err); class != tt.expectedClass {
+				t.Errorf("incorrect class name, expected: %s, got %s", tt.expectedClass, class)
+			}
+		})
+	}
+}
+
+func TestClassify_rawType(t *testing.T) {
+	c := NewErrorCollector()
+	c.ReportError(fmt.Errorf("query: %w", &timeoutError{}))
+
+	errorWithContext := getFirstAggregatedErr(c.aggregatedErrors).LatestErrors[0]
+	if errorWithContext.Error.Class != "*periskop.timeoutError" {
+		t.Errorf("incorrect class name, got %s", errorWithContext.Error.Class)
+	}
+	if errorWithContext.Error.Type != "*fmt.wrapError" {
+		t.Errorf("incorrect type, got %s", errorWithContext.Error.Type)
+	}
+}
+
+func TestClassify_sanitizeFields(t *testing.T) {
+	fields := map[string]interface{}{"order_id": "123", "callback": func() {}}
+	sanitized := sanitizeFields(fields)
+	if _, ok := sanitized["callback"].(string); !ok || sanitized["order_id"] != "123" {
+		t.Errorf("unexpected sanitized fields: %v", sanitized)
+	}
+	if _, ok := fields["callback"].(func()); !ok {
+		t.Errorf("expected the reported fields not to change")
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+)
+
+// maxCrashOutput is the maximum size of the crash output kept by crashDetector
+const maxCrashOutput = 1 << 20
+
+var crashPrefixes = []string{"panic: ", "fatal error: "}
+
+// crashDetector is an io.Writer that looks for the output of a go program that crashed and
+// keeps it, from the last line starting with `panic: ` or `fatal error: ` until the end of the output.
+// Earlier lines with those prefixes are output of the program, like logged panics that were recovered.
+type crashDetector struct {
+	line   bytes.Buffer
+	output bytes.Buffer
+	found  bool
+}
+
+func (d *crashDetector) Write(p []byte) (int, error) {
+	for _, b := range p {
+		d.line.WriteByte(b)
+		if b == '\n' {
+			d.addLine()
+		}
+	}
+	return len(p), nil
+}
+
+func (d *crashDetector) addLine() {
+	defer d.line.Reset()
+	for _, prefix := range crashPrefixes {
+		if strings.HasPrefix(d.line.String(), prefix) {
+			d.found = true
+			d.output.Reset()
+		}
+	}
+	if d.found && d.output.Len()+d.line.Len() <= maxCrashOutput {
+		d.output.Write(d.line.Bytes())
+	}
+}
+
+// Crash returns the crash output found, or an empty string if the program didn't crash
+func (d *crashDetector) Crash() string {
+	if d.line.Len() > 0 {
+		// last line without a trailing newline
+		d.addLine()
+	}
+	return d.output.String()
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestCrashDetector(t *testing.T) {
+	crash := "panic: hello!\n\ngoroutine 1 [running]:\nmain.main()\n\t/go/src/example/main.go:10 +0x25"
+	cases := map[string]struct {
+		output   string
+		expected string
+	}{
+		"no crash":     {"starting\nlistening on :8080\n", ""},
+		"panic":        {"starting\n" + crash + "\n", crash + "\n"},
+		"no newline":   {"starting\n" + crash, crash},
+		"fatal error":  {"starting\nfatal error: concurrent map writes\n", "fatal error: concurrent map writes\n"},
+		"not at start": {"log: a panic: is not a crash\n", ""},
+		"recovered":    {"panic: logged and recovered\nstill running\n" + crash + "\n", crash + "\n"},
+	}
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			d := &crashDetector{}
+			// write in small chunks to split lines between writes
+			for i := 0; i < len(tt.output); i += 7 {
+				end := i + 7
+				if end > len(tt.output) {
+					end = len(tt.output)
+				}
+				fmt.Fprint(d, tt.output[i:end])
+			}
+			if crash := d.Crash(); crash != tt.expected {
+				t.Errorf("expected crash %q, got %q", tt.expected, crash)
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+// Command periskop-wrap runs a command and reports its panics and fatal errors to Periskop, detecting them
+// in the standard error of the command. Crashes are pushed to a pushgateway and/or exposed over HTTP.
+//
+// Usage:
+//
+//	periskop-wrap [-gateway addr] [-listen addr] [-grace duration] [-restart] command [args...]
+//
+// A crash is only reported when the command exits with a non-zero exit code (2 for go programs that crash)
+// or is killed by a signal. With -restart, the command is restarted after a crash with an exponential
+// backoff, which is reset when the command runs for longer than the maximum backoff.
+//
+// The exit code is the one of the command, or 128 plus the signal number when the command is killed by
+// a signal. When crashes are exposed over HTTP, they keep being exposed after the command exits until
+// the wrapper receives SIGTERM or SIGINT or the grace period ends, so they can be scraped.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/periskop-dev/periskop-go"
+)
+
+const (
+	minRestartBackoff = time.Second
+	maxRestartBackoff = time.Minute
+)
+
+func main() {
+	gateway := flag.String("gateway", "", "address of the pushgateway crashes are pushed to")
+	listen := flag.String("listen", "", "address where crashes are exposed in /-/exceptions")
+	restart := flag.Bool("restart", false, "restart the command when it crashes")
+	grace := flag.Duration("grace", time.Minute, "time crashes are exposed in -listen after the command exits")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] command [args...]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	c := periskop.NewErrorCollector()
+	e := periskop.NewErrorExporter(&c)
+	if *listen != "" {
+		http.Handle("/-/exceptions", periskop.NewHandler(e))
+		go func() {
+			log.Fatal(http.ListenAndServe(*listen, nil))
+		}()
+	}
+
+	crashed := false
+	exit := func(code int) {
+		if *listen != "" && crashed {
+			waitForScrape(*grace)
+		}
+		os.Exit(code)
+	}
+	backoff := minRestartBackoff
+	for {
+		start := time.Now()
+		code, crash, err := run(flag.Args())
+		if err != nil {
+			log.Fatalf("periskop-wrap: error running command: %s", err)
+		}
+		if crash == "" {
+			exit(code)
+		}
+
+		crashed = true
+		c.ReportPanicOutput(crash)
+		if *gateway != "" {
+			if err := e.PushToGateway(*gateway); err != nil {
+				log.Printf("periskop-wrap: error pushing crash to gateway: %s", err)
+			}
+		}
+		if !*restart {
+			exit(code)
+		}
+
+		if time.Since(start) > maxRestartBackoff {
+			backoff = minRestartBackoff
+		}
+		log.Printf("periskop-wrap: restarting command in %s", backoff)
+		if waitForSignal(backoff) {
+			exit(code)
+		}
+		backoff = nextBackoff(backoff)
+	}
+}
+
+// nextBackoff doubles the restart backoff, up to maxRestartBackoff
+func nextBackoff(backoff time.Duration) time.Duration {
+	backoff *= 2
+	if backoff > maxRestartBackoff {
+		return maxRestartBackoff
+	}
+	return backoff
+}
+
+// waitForScrape waits until the wrapper receives SIGTERM or SIGINT or the grace period ends
+func waitForScrape(grace time.Duration) {
+	waitForSignal(grace)
+}
+
+// waitForSignal waits for `d`, and returns true if the wrapper received SIGTERM or SIGINT before
+func waitForSignal(d time.Duration) bool {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-signals:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// exitCode gets the exit code of a command, or 128 plus the signal number if it was killed by a signal
+func exitCode(exitErr *exec.ExitError) int {
+	if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+	return exitErr.ExitCode()
+}
+
+// run runs the command, forwarding interrupt and termination signals to it, and returns its exit code
+// and the crash output found in its standard error, only when the command failed
+func run(args []string) (int, string, error) {
+	detector := &crashDetector{}
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = io.MultiWriter(os.Stderr, detector)
+	if err := cmd.Start(); err != nil {
+		return 0, "", err
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		for sig := range signals {
+			_ = cmd.Process.Signal(sig)
+		}
+	}()
+	defer func() {
+		signal.Stop(signals)
+		close(signals)
+	}()
+
+	err := cmd.Wait()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return exitCode(exitErr), detector.Crash(), nil
+	}
+	if err != nil {
+		return 0, "", err
+	}
+	// a go program that crashes exits with a non-zero code, any crash output is a recovered panic logged
+	return 0, "", nil
+}
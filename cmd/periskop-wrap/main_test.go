@@ -0,0 +1,80 @@
+package main
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestRun_exitCode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a unix shell")
+	}
+	cases := map[string]struct {
+		script   string
+		expected int
+	}{
+		"success":   {"exit 0", 0},
+		"failure":   {"exit 3", 3},
+		"SIGTERM":   {"kill -TERM $$", 128 + 15},
+		"SIGKILL":   {"kill -KILL $$", 128 + 9},
+		"fatal err": {"echo 'fatal error: out of memory' >&2; exit 2", 2},
+	}
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			code, _, err := run([]string{"sh", "-c", tt.script})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if code != tt.expected {
+				t.Errorf("expected exit code %d, got %d", tt.expected, code)
+			}
+		})
+	}
+}
+
+func TestRun_crash(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a unix shell")
+	}
+	cases := map[string]struct {
+		script string
+		crash  bool
+	}{
+		"crash":     {"echo 'panic: boom' >&2; exit 2", true},
+		"recovered": {"echo 'panic: boom' >&2; exit 0", false},
+		"signaled":  {"echo 'fatal error: out of memory' >&2; kill -KILL $$", true},
+	}
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, crash, err := run([]string{"sh", "-c", tt.script})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (crash != "") != tt.crash {
+				t.Errorf("expected crash %v, got %q", tt.crash, crash)
+			}
+		})
+	}
+}
+
+func TestNextBackoff(t *testing.T) {
+	backoff := minRestartBackoff
+	for i := 0; i < 10; i++ {
+		backoff = nextBackoff(backoff)
+	}
+	if backoff != maxRestartBackoff {
+		t.Errorf("expected the backoff to be capped to %s, got %s", maxRestartBackoff, backoff)
+	}
+	if nextBackoff(minRestartBackoff) != 2*minRestartBackoff {
+		t.Errorf("expected the backoff to double")
+	}
+}
+
+func TestWaitForScrape(t *testing.T) {
+	start := time.Now()
+	waitForScrape(10 * time.Millisecond)
+	if time.Since(start) < 10*time.Millisecond {
+		t.Errorf("expected to wait for the grace period")
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"reflect"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/periskop-dev/periskop-go/errutils"
@@ -26,6 +27,16 @@ type ErrorCollector struct {
 	aggregatedErrors map[string]*aggregatedError
 	mux              sync.RWMutex
 	uuid             uuid.UUID
+	hooks            []BeforeReportHook
+	multiErrorMode   MultiErrorMode
+	runtimeContext   *runtimeSnapshotter
+	target           Target
+	// version is increased every time an error is collected
+	version uint64
+	// droppedReports is the number of reports dropped by hooks
+	droppedReports uint64
+	// deltas holds the state of the delta pushes, shared by all the exporters of the collector
+	deltas *deltaStates
 }
 
 // NewErrorCollector creates a new ErrorCollector
@@ -33,9 +44,34 @@ func NewErrorCollector() ErrorCollector {
 	return ErrorCollector{
 		aggregatedErrors: make(map[string]*aggregatedError),
 		uuid:             uuid.New(),
+		target:           newTarget(),
+		deltas:           newDeltaStates(),
 	}
 }
 
+// AddBeforeReportHook adds hooks that are run, in the order they were added, before an error is collected
+func (c *ErrorCollector) AddBeforeReportHook(hooks ...BeforeReportHook) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.hooks = append(c.hooks, hooks...)
+}
+
+// SetMultiErrorMode sets how errors wrapping several errors (e.g. created with errors.Join) are reported
+func (c *ErrorCollector) SetMultiErrorMode(mode MultiErrorMode) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.multiErrorMode = mode
+}
+
+// EnableRuntimeContext attaches a snapshot of the state of the process (goroutines, memory, uptime...) to
+// every reported error. A new snapshot is taken at most once per `interval`, which defaults to
+// DefaultRuntimeContextInterval when zero.
+func (c *ErrorCollector) EnableRuntimeContext(interval time.Duration) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.runtimeContext = newRuntimeSnapshotter(interval)
+}
+
 // Report adds an error report to map of aggregated errors. Severity defaults to Error when missing.
 func (c *ErrorCollector) Report(report ErrorReport) {
 	if report.Severity == "" {
@@ -125,19 +161,47 @@ func getStackTrace(err error) []string {
 	e := errutils.New(err)
 	// get all the traces produced by the error skipping those
 	// traces generated by this package.
-	trace := string(e.Stack("periskop-go"))
-	s := strings.FieldsFunc(trace, func(c rune) bool { return c == '\n' })
-	return s
+	return splitStackTrace(e.Stack("periskop-go"))
 }
 
-func (c *ErrorCollector) getAggregatedErrors() payload {
+// formatStackFrames formats stack frames parsed from the output of a go program as a trace
+func formatStackFrames(frames []errutils.StackFrame) []string {
+	var trace []byte
+	for _, frame := range frames {
+		trace = append(trace, frame.String()...)
+	}
+	return splitStackTrace(trace)
+}
+
+func splitStackTrace(trace []byte) []string {
+	return strings.FieldsFunc(string(trace), func(c rune) bool { return c == '\n' })
+}
+
+// getAggregatedErrors gets the aggregated errors selected by `filter`
+func (c *ErrorCollector) getAggregatedErrors(filter errorsFilter) payload {
 	c.mux.RLock()
 	defer c.mux.RUnlock()
 	aggregatedErrors := make([]aggregatedError, 0)
 	for _, value := range c.aggregatedErrors {
-		aggregatedErrors = append(aggregatedErrors, *value)
+		if aggregatedErr, ok := filter.filter(value); ok {
+			aggregatedErrors = append(aggregatedErrors, aggregatedErr)
+		}
 	}
-	return payload{aggregatedErrors, c.uuid}
+	return payload{AggregatedErrors: filter.apply(aggregatedErrors), TargetUUID: c.uuid, Target: c.target.copy()}
+}
+
+// getVersion gets a number that changes every time an error is collected
+func (c *ErrorCollector) getVersion() uint64 {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+	return c.version
+}
+
+// getDroppedReports gets the number of reports dropped by hooks
+func (c *ErrorCollector) getDroppedReports() uint64 {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+	return c.droppedReports
 }
 
 // getAggregationKey gets the aggregation key of the error
@@ -149,18 +213,86 @@ func getAggregationKey(errorWithContext ErrorWithContext, errKey string) string
 	return errorWithContext.aggregationKey()
 }
 
-// addError adds an error to map of aggregated errors
+// addError adds an error to map of aggregated errors. Errors wrapping several errors are reported
+// according to the MultiErrorMode of the collector.
 func (c *ErrorCollector) addError(err error, severity Severity, httpCtx *HTTPContext, errKey string) {
-	errorInstance := newErrorInstance(err, reflect.TypeOf(err).String(), getStackTrace(err))
-	errWithContext := NewErrorWithContext(errorInstance, severity, httpCtx)
-	c.addErrorWithContext(errWithContext, severity, errKey)
+	c.mux.RLock()
+	multiErrorMode := c.multiErrorMode
+	c.mux.RUnlock()
+
+	errs := unwrapMultiError(err)
+	if len(errs) > 0 && multiErrorMode == MultiErrorFanOut {
+		correlationID := uuid.New().String()
+		for _, err := range errs {
+			errWithContext := newErrorWithContextFromError(err, severity, httpCtx)
+			errWithContext.CorrelationID = correlationID
+			c.addErrorWithContext(errWithContext, errWithContext.Severity, getErrorKey(err, errKey))
+		}
+		return
+	}
+
+	errWithContext := newErrorWithContextFromError(err, severity, httpCtx)
+	if len(errs) > 0 && multiErrorMode == MultiErrorCauses {
+		for _, err := range errs {
+			// causes have no stacktrace, as it would be the same one of the multi-error
+			errWithContext.Error.Causes = append(errWithContext.Error.Causes, newErrorInstanceFromError(err, nil))
+		}
+	}
+	c.addErrorWithContext(errWithContext, errWithContext.Severity, getErrorKey(err, errKey))
+}
+
+// newErrorWithContextFromError creates an ErrorWithContext from a Go error. Severity, class and fields
+// defined by the error itself (see ErrorWithSeverity and similar interfaces) are honoured.
+func newErrorWithContextFromError(err error, severity Severity, httpCtx *HTTPContext) ErrorWithContext {
+	errorInstance := newErrorInstanceFromError(err, getStackTrace(err))
+	errWithContext := NewErrorWithContext(errorInstance, getErrorSeverity(err, severity), httpCtx)
+	errWithContext.Fields = getErrorFields(err)
+	errWithContext.err = err
+	return errWithContext
+}
+
+// newErrorInstanceFromError creates an ErrorInstance from a Go error, keeping its Go type when it's
+// different from its class
+func newErrorInstanceFromError(err error, stacktrace []string) ErrorInstance {
+	errorInstance := newErrorInstance(err, getErrorClass(err), stacktrace)
+	if errType := reflect.TypeOf(err).String(); errType != errorInstance.Class {
+		errorInstance.Type = errType
+	}
+	return errorInstance
 }
 
 // addErrorWithContext adds a manually generated ErrorWithContext to map of aggregated errors
 func (c *ErrorCollector) addErrorWithContext(errWithContext ErrorWithContext, severity Severity, errKey string) {
-	aggregationKey := getAggregationKey(errWithContext, errKey)
+	if len(errKey) > 0 {
+		errWithContext.ErrKey = errKey
+	}
+	if errWithContext.Mechanism == "" {
+		errWithContext.Mechanism = MechanismManual
+	}
+	c.mux.RLock()
+	hooks := c.hooks
+	runtimeContext := c.runtimeContext
+	c.mux.RUnlock()
+	if runtimeContext != nil && errWithContext.Runtime == nil {
+		errWithContext.Runtime = runtimeContext.snapshot()
+	}
+	reportedSeverity := errWithContext.Severity
+	if !runHooks(hooks, &errWithContext) {
+		c.mux.Lock()
+		c.droppedReports++
+		c.mux.Unlock()
+		return
+	}
+	if errWithContext.Severity != reportedSeverity {
+		// severity was changed by a hook
+		severity = errWithContext.Severity
+	}
+	errWithContext.Fields = sanitizeFields(errWithContext.Fields)
+
+	aggregationKey := getAggregationKey(errWithContext, errWithContext.ErrKey)
 	c.mux.Lock()
 	defer c.mux.Unlock()
+	c.version++
 	if aggregatedErr, ok := c.aggregatedErrors[aggregationKey]; ok {
 		aggregatedErr.addError(errWithContext)
 	} else {
@@ -53,6 +53,11 @@ func TestCollector_ReportError(t *testing.T) {
 	if errorWithContext.Severity != SeverityError {
 		t.Errorf("incorrect severity, got %s", SeverityError)
 	}
+
+	if errorWithContext.Unhandled || errorWithContext.Mechanism != MechanismManual {
+		t.Errorf("expected a handled manual error, got unhandled=%v mechanism=%s",
+			errorWithContext.Unhandled, errorWithContext.Mechanism)
+	}
 }
 
 func TestErrorCollector_ReportWithSeverity(t *testing.T) {
@@ -270,13 +275,25 @@ func TestCollector_ReportErrorWithContext(t *testing.T) {
 	}
 }
 
+func TestCollector_ReportErrorWithContext_literal(t *testing.T) {
+	c := NewErrorCollector()
+	c.ReportErrorWithContext(ErrorWithContext{Error: ErrorInstance{Class: "manual_error", Message: "testing"}},
+		SeverityError, "testing")
+
+	errorWithContext := getFirstAggregatedErr(c.aggregatedErrors).LatestErrors[0]
+	if errorWithContext.Unhandled || errorWithContext.Mechanism != MechanismManual {
+		t.Errorf("expected a handled manual error, got unhandled=%v mechanism=%s",
+			errorWithContext.Unhandled, errorWithContext.Mechanism)
+	}
+}
+
 func TestCollector_getAggregatedErrors(t *testing.T) {
 	c := NewErrorCollector()
 	err := errors.New("testing")
 	c.addError(err, SeverityError, nil, "")
 
 	aggregatedErr := getFirstAggregatedErr(c.aggregatedErrors)
-	payload := c.getAggregatedErrors()
+	payload := c.getAggregatedErrors(errorsFilter{})
 	if payload.AggregatedErrors[0].AggregationKey != aggregatedErr.AggregationKey {
 		t.Errorf("keys for aggregated errors are different, expected: %s, got: %s",
 			aggregatedErr.AggregationKey, payload.AggregatedErrors[0].AggregationKey)
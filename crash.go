@@ -0,0 +1,51 @@
+package periskop
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/periskop-dev/periskop-go/errutils"
+)
+
+// CaptureCrashOutput reports the crash of a previous run of the process written to the spool file at `path`
+// (see ReportCrashOutput) and makes the runtime write the output of fatal errors of this process, like
+// unrecovered panics or `concurrent map writes`, to it. It requires Go 1.23 or later.
+func (c *ErrorCollector) CaptureCrashOutput(path string) error {
+	if err := c.ReportCrashOutput(path); err != nil {
+		return err
+	}
+	return setCrashOutput(path)
+}
+
+// ReportCrashOutput reports the crash output written to the spool file at `path`, if any, as a critical
+// unhandled error and deletes the file
+func (c *ErrorCollector) ReportCrashOutput(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if crash := strings.TrimSpace(string(data)); crash != "" {
+		c.ReportPanicOutput(crash)
+	}
+	return os.Remove(path)
+}
+
+// ReportPanicOutput reports the output of a go program that crashed (starting with `panic: `) as a critical
+// unhandled error. Output that can't be parsed is reported with its first line as the message.
+func (c *ErrorCollector) ReportPanicOutput(output string) {
+	var errorInstance ErrorInstance
+	if e, err := errutils.ParsePanic(output); err == nil {
+		errorInstance = NewCustomErrorInstance(e.Error(), e.TypeName(), formatStackFrames(e.StackFrames()))
+	} else {
+		lines := strings.Split(output, "\n")
+		errorInstance = NewCustomErrorInstance(lines[0], "crash", lines[1:])
+	}
+	errWithContext := NewErrorWithContext(errorInstance, SeverityCritical, nil)
+	errWithContext.Unhandled = true
+	errWithContext.Mechanism = MechanismPanicParse
+	c.addErrorWithContext(errWithContext, SeverityCritical, "")
+}
@@ -0,0 +1,19 @@
+//go:build go1.23
+// +build go1.23
+
+package periskop
+
+import (
+	"os"
+	"runtime/debug"
+)
+
+func setCrashOutput(path string) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	// SetCrashOutput duplicates the file descriptor, so it can be closed right away
+	defer f.Close()
+	return debug.SetCrashOutput(f, debug.CrashOptions{})
+}
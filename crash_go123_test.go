@@ -0,0 +1,45 @@
+//go:build go1.23
+// +build go1.23
+
+package periskop
+
+import (
+	"os"
+	"os/exec"
+	"testing"
+)
+
+func TestCrash_CaptureCrashOutput(t *testing.T) {
+	if path := os.Getenv("PERISKOP_CRASH_SPOOL"); path != "" {
+		c := NewErrorCollector()
+		if err := c.CaptureCrashOutput(path); err != nil {
+			t.Fatal(err)
+		}
+		go func() {
+			panic("crashed!")
+		}()
+		select {}
+	}
+
+	path := writeSpool(t, "")
+	cmd := exec.Command(os.Args[0], "-test.run=TestCrash_CaptureCrashOutput")
+	cmd.Env = append(os.Environ(), "PERISKOP_CRASH_SPOOL="+path)
+	if err := cmd.Run(); err == nil {
+		t.Fatalf("expected process to crash")
+	}
+
+	c := NewErrorCollector()
+	if err := c.ReportCrashOutput(path); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.aggregatedErrors) != 1 {
+		t.Fatalf("expected one element")
+	}
+	errorWithContext := getFirstAggregatedErr(c.aggregatedErrors).LatestErrors[0]
+	if errorWithContext.Error.Message != "crashed!" || errorWithContext.Error.Class != "panic" {
+		t.Errorf("unexpected error: %+v", errorWithContext.Error)
+	}
+	if len(errorWithContext.Error.Stacktrace) == 0 {
+		t.Errorf("expected a parsed stack trace")
+	}
+}
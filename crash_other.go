@@ -0,0 +1,12 @@
+//go:build !go1.23
+// +build !go1.23
+
+package periskop
+
+import (
+	"errors"
+)
+
+func setCrashOutput(path string) error {
+	return errors.New("capturing crash output requires Go 1.23 or later")
+}
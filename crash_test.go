@@ -0,0 +1,67 @@
+package periskop
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var crashOutput = `panic: hello!
+
+goroutine 1 [running]:
+main.main()
+	/go/src/example/main.go:10 +0x25
+`
+
+func writeSpool(t *testing.T, data string) string {
+	dir, err := ioutil.TempDir("", "periskop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, "crash.log")
+	if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCrash_ReportCrashOutput(t *testing.T) {
+	c := NewErrorCollector()
+	path := writeSpool(t, crashOutput)
+	if err := c.ReportCrashOutput(path); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(c.aggregatedErrors) != 1 {
+		t.Fatalf("expected one element")
+	}
+	aggregatedErr := getFirstAggregatedErr(c.aggregatedErrors)
+	errorWithContext := aggregatedErr.LatestErrors[0]
+	if errorWithContext.Error.Message != "hello!" || errorWithContext.Error.Class != "panic" {
+		t.Errorf("unexpected error: %+v", errorWithContext.Error)
+	}
+	if len(errorWithContext.Error.Stacktrace) != 1 || errorWithContext.Error.Stacktrace[0] != "/go/src/example/main.go:10" {
+		t.Errorf("unexpected stacktrace: %v", errorWithContext.Error.Stacktrace)
+	}
+	if aggregatedErr.Severity != SeverityCritical || !errorWithContext.Unhandled {
+		t.Errorf("expected a critical unhandled error")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected spool to be deleted")
+	}
+}
+
+func TestCrash_ReportCrashOutput_empty(t *testing.T) {
+	c := NewErrorCollector()
+	if err := c.ReportCrashOutput(writeSpool(t, "")); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ReportCrashOutput(filepath.Join(os.TempDir(), "missing-periskop-spool")); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.aggregatedErrors) != 0 {
+		t.Errorf("expected no errors")
+	}
+}
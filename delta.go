@@ -0,0 +1,117 @@
+package periskop
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// deltaStates holds the state of the delta pushes to every pushgateway, or group of pushgateways
+type deltaStates struct {
+	mux    sync.Mutex
+	states map[string]*deltaState
+}
+
+func newDeltaStates() *deltaStates {
+	return &deltaStates{states: make(map[string]*deltaState)}
+}
+
+func (d *deltaStates) get(key string) *deltaState {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	state, ok := d.states[key]
+	if !ok {
+		state = &deltaState{acked: make(map[string]int)}
+		d.states[key] = state
+	}
+	return state
+}
+
+// deltaState tracks what a pushgateway acknowledged
+type deltaState struct {
+	// mux serializes the delta pushes to the gateway
+	mux      sync.Mutex
+	sequence uint64
+	// acked is the total count of every aggregated error acknowledged by the gateway
+	acked map[string]int
+	// pending is the last push, if it failed
+	pending *deltaPush
+}
+
+// deltaPush is a delta push, kept until the gateway acknowledges it
+type deltaPush struct {
+	data           []byte
+	idempotencyKey string
+	// totalCounts are the total counts of the aggregated errors included in the push
+	totalCounts map[string]int
+}
+
+func (e *ErrorExporter) pushDelta(ctx context.Context, deltaKey string, send sendFunc, opts PushOptions) error {
+	state := e.collector.deltas.get(deltaKey)
+	state.mux.Lock()
+	defer state.mux.Unlock()
+
+	if state.pending != nil {
+		// send the failed push again, in case the gateway counted it without acknowledging it
+		if err := state.push(ctx, send, opts); err != nil {
+			return err
+		}
+	}
+
+	p := state.delta(e.collector.getAggregatedErrors(errorsFilter{}))
+	if p == nil {
+		return nil
+	}
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	state.pending = &deltaPush{data: data, idempotencyKey: p.IdempotencyKey, totalCounts: make(map[string]int)}
+	for _, aggregatedErr := range p.AggregatedErrors {
+		state.pending.totalCounts[aggregatedErr.AggregationKey] = state.acked[aggregatedErr.AggregationKey] +
+			aggregatedErr.TotalCount
+	}
+	return state.push(ctx, send, opts)
+}
+
+// push pushes the pending delta, which is acknowledged if the push succeeds or it's kept in the spool
+func (s *deltaState) push(ctx context.Context, send sendFunc, opts PushOptions) error {
+	spooled, err := pushOrSpool(ctx, send, s.pending.data, s.pending.idempotencyKey, opts)
+	if err != nil && !spooled {
+		return err
+	}
+	for key, totalCount := range s.pending.totalCounts {
+		s.acked[key] = totalCount
+	}
+	s.pending = nil
+	return err
+}
+
+// delta gets the errors collected since the last acknowledged push, or nil if there are none
+func (s *deltaState) delta(full payload) *payload {
+	var aggregatedErrors []aggregatedError
+	for _, aggregatedErr := range full.AggregatedErrors {
+		increment := aggregatedErr.TotalCount - s.acked[aggregatedErr.AggregationKey]
+		if increment <= 0 {
+			continue
+		}
+		latestErrors := aggregatedErr.LatestErrors
+		if len(latestErrors) > increment {
+			latestErrors = latestErrors[len(latestErrors)-increment:]
+		}
+		aggregatedErr.TotalCount = increment
+		aggregatedErr.LatestErrors = latestErrors
+		aggregatedErrors = append(aggregatedErrors, aggregatedErr)
+	}
+	if len(aggregatedErrors) == 0 {
+		return nil
+	}
+
+	s.sequence++
+	full.AggregatedErrors = aggregatedErrors
+	full.Delta = true
+	full.Sequence = s.sequence
+	full.IdempotencyKey = fmt.Sprintf("%s-%d", full.TargetUUID, s.sequence)
+	return &full
+}
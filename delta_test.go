@@ -0,0 +1,103 @@
+package periskop
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestDelta_PushToGatewayWithContext(t *testing.T) {
+	gateway := newFakeGateway()
+	defer gateway.Close()
+
+	c := NewErrorCollector()
+	e := NewErrorExporter(&c)
+	opts := PushOptions{Delta: true}
+	errTest := errors.New("testing")
+	c.ReportWithSeverity(errTest, SeverityError)
+	c.ReportWithSeverity(errTest, SeverityError)
+	if err := e.PushToGatewayWithContext(context.Background(), gateway.URL, opts); err != nil {
+		t.Fatalf("error pushing exceptions: %v", err)
+	}
+	// nothing new to push
+	if err := e.PushToGatewayWithContext(context.Background(), gateway.URL, opts); err != nil {
+		t.Fatalf("error pushing exceptions: %v", err)
+	}
+	payloads := gateway.pushed()
+	if len(payloads) != 1 {
+		t.Fatalf("expected one push, got %d", len(payloads))
+	}
+	first := payloads[0]
+	if !first.Delta || first.Sequence != 1 || first.IdempotencyKey == "" {
+		t.Errorf("expected a delta push with a sequence number and idempotency key, got %+v", first)
+	}
+	if first.AggregatedErrors[0].TotalCount != 2 || len(first.AggregatedErrors[0].LatestErrors) != 2 {
+		t.Errorf("expected two errors, got %+v", first.AggregatedErrors[0])
+	}
+
+	c.ReportWithSeverity(errTest, SeverityError)
+	gateway.setLost(true)
+	if err := e.PushToGatewayWithContext(context.Background(), gateway.URL, opts); err == nil {
+		t.Fatalf("expected push to fail")
+	}
+	c.ReportWithSeverity(errTest, SeverityError)
+	gateway.setLost(false)
+	if err := e.PushToGatewayWithContext(context.Background(), gateway.URL, opts); err != nil {
+		t.Fatalf("error pushing exceptions: %v", err)
+	}
+
+	payloads = gateway.pushed()
+	if len(payloads) != 4 {
+		t.Fatalf("expected four pushes, got %d", len(payloads))
+	}
+	failed, retried, last := payloads[1], payloads[2], payloads[3]
+	if retried.IdempotencyKey != failed.IdempotencyKey || retried.Sequence != 2 {
+		t.Errorf("expected the failed push to be sent again, got %+v", retried)
+	}
+	if retried.AggregatedErrors[0].TotalCount != 1 {
+		t.Errorf("expected the failed push to be sent unchanged")
+	}
+	if last.Sequence != 3 || last.AggregatedErrors[0].TotalCount != 1 || len(last.AggregatedErrors[0].LatestErrors) != 1 {
+		t.Errorf("expected only the new error, got %+v", last)
+	}
+
+	// counting every push once, the gateway gets the total count of the collector
+	seen := make(map[string]bool)
+	total := 0
+	for _, p := range payloads {
+		if seen[p.IdempotencyKey] {
+			continue
+		}
+		seen[p.IdempotencyKey] = true
+		total += p.AggregatedErrors[0].TotalCount
+	}
+	if total != 4 {
+		t.Errorf("expected a total count of 4, got %d", total)
+	}
+}
+
+func TestDelta_exporters(t *testing.T) {
+	gateway := newFakeGateway()
+	defer gateway.Close()
+
+	c := NewErrorCollector()
+	opts := PushOptions{Delta: true}
+	for i := 0; i < 3; i++ {
+		c.ReportError(errors.New("testing"))
+		e := NewErrorExporter(&c)
+		if err := e.PushToGatewayWithContext(context.Background(), gateway.URL, opts); err != nil {
+			t.Fatalf("error pushing exceptions: %v", err)
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, p := range gateway.pushed() {
+		if seen[p.IdempotencyKey] {
+			t.Errorf("expected a new idempotency key for every push, got %s twice", p.IdempotencyKey)
+		}
+		seen[p.IdempotencyKey] = true
+		if p.AggregatedErrors[0].TotalCount != 1 {
+			t.Errorf("expected push %d to have one new error, got %d", p.Sequence, p.AggregatedErrors[0].TotalCount)
+		}
+	}
+}
@@ -216,5 +216,8 @@ func (err *Error) TypeName() string {
 	if _, ok := err.Err.(uncaughtPanic); ok {
 		return "panic"
 	}
+	if _, ok := err.Err.(fatalError); ok {
+		return "fatal error"
+	}
 	return reflect.TypeOf(err.Err).String()
 }
@@ -27,7 +27,8 @@ func TestStackFormat(t *testing.T) {
 			t.Errorf(err.Error())
 		}
 
-		stack := string(e.Stack(""))
+		// Stack("") skips all the frames, so skip a package that isn't in the stack
+		stack := string(e.Stack("unrelated-package"))
 
 		if !strings.Contains(stack, "a: b(5)") {
 			t.Errorf("Stack trace does not contain source line: 'a: b(5)'")
@@ -168,19 +169,24 @@ func TestWrapPrefixError(t *testing.T) {
 	}
 }
 
-func ExampleErrorf(x int) (int, error) {
-	if x%2 == 1 {
-		return 0, Errorf("can only halve even numbers, got %d", x)
+func ExampleErrorf() {
+	halve := func(x int) (int, error) {
+		if x%2 == 1 {
+			return 0, Errorf("can only halve even numbers, got %d", x)
+		}
+		return x / 2, nil
 	}
-	return x / 2, nil
+	_, err := halve(3)
+	fmt.Println(err)
 }
 
-func ExampleWrapError() (error, error) {
+func ExampleWrap_eof() {
 	// Wrap io.EOF with the current stack-trace and return it
-	return nil, Wrap(io.EOF, 0)
+	err := Wrap(io.EOF, 0)
+	fmt.Println(err)
 }
 
-func ExampleWrapError_skip() {
+func ExampleWrap_skip() {
 	defer func() {
 		if err := recover(); err != nil {
 			// skip 1 frame (the deferred function) and then return the wrapped err
@@ -189,44 +195,52 @@ func ExampleWrapError_skip() {
 	}()
 }
 
-func ExampleIs(reader io.Reader, buff []byte) {
-	_, err := reader.Read(buff)
+func ExampleIs() {
+	reader := strings.NewReader("")
+	_, err := reader.Read(make([]byte, 1))
 	if Is(err, io.EOF) {
 		return
 	}
 }
 
-func ExampleNew(UnexpectedEOF error) error {
+func ExampleNew() {
 	// calling New attaches the current stacktrace to the existing UnexpectedEOF error
-	return New(UnexpectedEOF)
+	err := New(io.ErrUnexpectedEOF)
+	fmt.Println(err)
 }
 
-func ExampleWrap() error {
-
-	if err := recover(); err != nil {
-		return Wrap(err, 1)
-	}
+func ExampleWrap() {
+	defer func() {
+		if err := recover(); err != nil {
+			fmt.Println(Wrap(err, 1))
+		}
+	}()
 
-	return a()
+	a()
 }
 
-func ExampleError_Error(err error) {
+func ExampleError_Error() {
+	err := Errorf("oh dear")
 	fmt.Println(err.Error())
 }
 
-func ExampleError_ErrorStack(err error) {
+func ExampleError_ErrorStack() {
+	var err error = Errorf("oh dear")
 	fmt.Println(err.(*Error).ErrorStack())
 }
 
-func ExampleError_Stack(err *Error) {
+func ExampleError_Stack() {
+	err := Errorf("oh dear")
 	fmt.Println(err.Stack(""))
 }
 
-func ExampleError_TypeName(err *Error) {
+func ExampleError_TypeName() {
+	err := Errorf("oh dear")
 	fmt.Println(err.TypeName(), err.Error())
 }
 
-func ExampleError_StackFrames(err *Error) {
+func ExampleError_StackFrames() {
+	err := Errorf("oh dear")
 	for _, frame := range err.StackFrames() {
 		fmt.Println(frame.File, frame.LineNumber, frame.Package, frame.Name)
 	}
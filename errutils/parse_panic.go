@@ -11,74 +11,283 @@ func (p uncaughtPanic) Error() string {
 	return p.message
 }
 
+type fatalError struct{ message string }
+
+func (p fatalError) Error() string {
+	return p.message
+}
+
+// Traceback is the parsed output of a go program that crashed
+type Traceback struct {
+	// Message of the panic that crashed the program, or of the fatal error
+	Message string
+	// Fatal is true for fatal errors (`fatal error: ...`), which can't be recovered
+	Fatal bool
+	// Panics holds the messages of all the panics that happened, like in
+	// `panic: first [recovered]` followed by `panic: second`, in the order they happened
+	Panics []string
+	// Signal that caused the crash, if any
+	Signal *Signal
+	// Goroutines holds every goroutine in the output, in the order they were printed
+	Goroutines []Goroutine
+}
+
+// Signal holds the information of a line like
+// `[signal SIGSEGV: segmentation violation code=0x1 addr=0x0 pc=0x47a1b2]`
+type Signal struct {
+	Name        string
+	Description string
+	Code        string
+	Addr        string
+	PC          string
+}
+
+// Goroutine is a goroutine of a traceback
+type Goroutine struct {
+	ID int
+	// State of the goroutine, like `running` or `chan receive, 2 minutes`
+	State  string
+	Frames []StackFrame
+	// CreatedBy is the frame that created the goroutine, if it was printed
+	CreatedBy *StackFrame
+	// CreatedByGoroutine is the ID of the goroutine that created this one, when known
+	CreatedByGoroutine int
+	// FramesElided is true if the runtime didn't print all the frames of the goroutine
+	FramesElided bool
+}
+
+// Running returns whether the goroutine was running when the program crashed
+func (g *Goroutine) Running() bool {
+	return strings.HasPrefix(g.State, "running")
+}
+
+// Crashed returns the goroutine that was running when the program crashed. For crashes with no running
+// goroutine (e.g. `all goroutines are asleep - deadlock!`) it returns the first goroutine.
+func (t *Traceback) Crashed() *Goroutine {
+	if len(t.Goroutines) == 0 {
+		return nil
+	}
+	for i := range t.Goroutines {
+		if t.Goroutines[i].Running() {
+			return &t.Goroutines[i]
+		}
+	}
+	return &t.Goroutines[0]
+}
+
 // ParsePanic allows you to get an error object from the output of a go program
 // that panicked. This is particularly useful with https://github.com/mitchellh/panicwrap.
+// The stack of the error is the one of the goroutine that crashed (see ParseTraceback for
+// the rest of the information of the output).
 func ParsePanic(text string) (*Error, error) {
-	lines := strings.Split(text, "\n")
+	traceback, err := ParseTraceback(text)
+	if err != nil {
+		return nil, err
+	}
 
-	state := "start"
+	g := traceback.Crashed()
+	stack := append([]StackFrame{}, g.Frames...)
+	if g.CreatedBy != nil {
+		stack = append(stack, *g.CreatedBy)
+	}
+	if traceback.Fatal {
+		return &Error{Err: fatalError{traceback.Message}, frames: stack}, nil
+	}
+	return &Error{Err: uncaughtPanic{traceback.Message}, frames: stack}, nil
+}
+
+// ParseTraceback parses the output of a go program that crashed, either with a panic or a fatal error.
+// Output printed before the `panic: ` or `fatal error: ` line is ignored.
+func ParseTraceback(text string) (*Traceback, error) {
+	lines := strings.Split(strings.Replace(text, "\r\n", "\n", -1), "\n")
+
+	i := 0
+	for i < len(lines) && !strings.HasPrefix(lines[i], "panic: ") && !strings.HasPrefix(lines[i], "fatal error: ") {
+		i++
+	}
+	if i == len(lines) {
+		return nil, Errorf("bugsnag.panicParser: Invalid output (no panic or fatal error): %s", text)
+	}
 
-	var message string
-	var stack []StackFrame
+	traceback := &Traceback{}
+	i = parseHeader(lines, i, traceback)
+
+	goroutines, err := parseGoroutines(lines[i:])
+	if err != nil {
+		return nil, err
+	}
+	if len(goroutines) == 0 {
+		return nil, Errorf("could not parse panic: %v", text)
+	}
+	traceback.Goroutines = goroutines
+	return traceback, nil
+}
 
+// parseGoroutines parses all the goroutines found in the lines, ignoring any other output
+func parseGoroutines(lines []string) ([]Goroutine, error) {
+	var goroutines []Goroutine
 	for i := 0; i < len(lines); i++ {
+		if !isGoroutineHeader(lines[i]) {
+			// other output, like `runtime stack:` blocks or `exit status 2`
+			continue
+		}
+		g, next, err := parseGoroutine(lines, i)
+		if err != nil {
+			return nil, err
+		}
+		goroutines = append(goroutines, *g)
+		i = next
+	}
+	return goroutines, nil
+}
+
+func isGoroutineHeader(line string) bool {
+	return strings.HasPrefix(line, "goroutine ") && strings.HasSuffix(line, "]:")
+}
+
+// parseHeader parses the lines with the panics (or the fatal error) and the signal, starting at
+// line `i`, and returns the index of the first line after them. Continuation lines of multiline
+// messages and nested panics are indented with a tab:
+//
+//	panic: first [recovered]
+//		panic: second
+//		with a second line
+//	[signal SIGSEGV: segmentation violation code=0x1 addr=0x0 pc=0x47a1b2]
+func parseHeader(lines []string, i int, traceback *Traceback) int {
+	if strings.HasPrefix(lines[i], "fatal error: ") {
+		traceback.Fatal = true
+		traceback.Panics = []string{strings.TrimPrefix(lines[i], "fatal error: ")}
+	} else {
+		traceback.Panics = []string{strings.TrimPrefix(lines[i], "panic: ")}
+	}
+
+	for i++; i < len(lines); i++ {
 		line := lines[i]
+		last := len(traceback.Panics) - 1
+		if strings.HasPrefix(line, "\tpanic: ") {
+			traceback.Panics = append(traceback.Panics, strings.TrimPrefix(line, "\tpanic: "))
+		} else if strings.HasPrefix(line, "\t") {
+			traceback.Panics[last] += "\n" + strings.TrimPrefix(line, "\t")
+		} else if strings.HasPrefix(line, "[signal ") {
+			traceback.Signal = parseSignal(line)
+		} else {
+			break
+		}
+	}
 
-		if state == "start" {
-			if strings.HasPrefix(line, "panic: ") {
-				message = strings.TrimPrefix(line, "panic: ")
-				state = "seek"
-			} else {
-				return nil, Errorf("bugsnag.panicParser: Invalid line (no prefix): %s", line)
-			}
+	for idx, message := range traceback.Panics {
+		traceback.Panics[idx] = trimRecovered(message)
+	}
+	traceback.Message = traceback.Panics[len(traceback.Panics)-1]
+	return i
+}
 
-		} else if state == "seek" {
-			if strings.HasPrefix(line, "goroutine ") && strings.HasSuffix(line, "[running]:") {
-				state = "parsing"
-			}
+// trimRecovered removes the ` [recovered]` (or ` [recovered, repanicked]`) suffix of a panic message
+func trimRecovered(message string) string {
+	if idx := strings.LastIndex(message, " [recovered"); idx != -1 && strings.HasSuffix(message, "]") {
+		return message[:idx]
+	}
+	return message
+}
 
-		} else if state == "parsing" {
-			if line == "" {
-				state = "done"
-				break
-			}
-			createdBy := false
-			if strings.HasPrefix(line, "created by ") {
-				line = strings.TrimPrefix(line, "created by ")
-				createdBy = true
-			}
+// parseSignal parses a line like `[signal SIGSEGV: segmentation violation code=0x1 addr=0x0 pc=0x47a1b2]`
+func parseSignal(line string) *Signal {
+	line = strings.TrimSuffix(strings.TrimPrefix(line, "[signal "), "]")
+	signal := &Signal{}
+	if idx := strings.Index(line, ":"); idx != -1 {
+		signal.Name = line[:idx]
+		line = strings.TrimSpace(line[idx+1:])
+	}
+	var description []string
+	for _, field := range strings.Fields(line) {
+		switch {
+		case strings.HasPrefix(field, "code="):
+			signal.Code = strings.TrimPrefix(field, "code=")
+		case strings.HasPrefix(field, "addr="):
+			signal.Addr = strings.TrimPrefix(field, "addr=")
+		case strings.HasPrefix(field, "pc="):
+			signal.PC = strings.TrimPrefix(field, "pc=")
+		case signal.Name == "":
+			signal.Name = field
+		default:
+			description = append(description, field)
+		}
+	}
+	signal.Description = strings.Join(description, " ")
+	return signal
+}
 
-			i++
+// parseGoroutine parses the goroutine starting at line `i`, with a header like
+// `goroutine 1 [running]:` or `goroutine 1 gp=0xc000002380 m=0 mp=0x53a520 [running, locked to thread]:`.
+// It returns the index of the last line of the goroutine.
+func parseGoroutine(lines []string, i int) (*Goroutine, int, error) {
+	header := lines[i]
+	fields := strings.Fields(header)
+	id, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return nil, i, Errorf("bugsnag.panicParser: Invalid line (bad goroutine id): %s", header)
+	}
+	g := &Goroutine{ID: id}
+	if idx := strings.LastIndex(header, "["); idx != -1 {
+		g.State = strings.TrimSuffix(header[idx+1:], "]:")
+	}
+	i, err = parseGoroutineFrames(lines, i+1, g)
+	return g, i, err
+}
 
-			if i >= len(lines) {
-				return nil, Errorf("bugsnag.panicParser: Invalid line (unpaired): %s", line)
-			}
+// parseGoroutineFrames parses the frames of a goroutine starting at line `i` and returns the index of
+// the last line of the goroutine, which ends with an empty line or a line that is not a frame
+func parseGoroutineFrames(lines []string, i int, g *Goroutine) (int, error) {
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if line == "" {
+			break
+		}
+		if strings.HasPrefix(line, "...") && strings.HasSuffix(line, "elided...") {
+			// `...additional frames elided...` or `...10 frames elided...`
+			g.FramesElided = true
+			continue
+		}
 
-			frame, err := parsePanicFrame(line, lines[i], createdBy)
-			if err != nil {
-				return nil, err
-			}
+		if i+1 >= len(lines) || !strings.HasPrefix(lines[i+1], "\t") {
+			// not a frame, like the `exit status 2` printed by go run
+			return i - 1, nil
+		}
 
-			stack = append(stack, *frame)
-			if createdBy {
-				state = "done"
-				break
+		createdBy := false
+		if strings.HasPrefix(line, "created by ") {
+			line = strings.TrimPrefix(line, "created by ")
+			if idx := strings.Index(line, " in goroutine "); idx != -1 {
+				g.CreatedByGoroutine, _ = strconv.Atoi(line[idx+len(" in goroutine "):])
+				line = line[:idx]
 			}
+			createdBy = true
 		}
-	}
 
-	if state == "done" || state == "parsing" {
-		return &Error{Err: uncaughtPanic{message}, frames: stack}, nil
+		i++
+		frame, err := parsePanicFrame(line, lines[i], createdBy)
+		if err != nil {
+			return i, err
+		}
+
+		if createdBy {
+			g.CreatedBy = frame
+		} else {
+			g.Frames = append(g.Frames, *frame)
+		}
 	}
-	return nil, Errorf("could not parse panic: %v", text)
+	return i, nil
 }
 
 // The lines we're passing look like this:
 //
-//     main.(*foo).destruct(0xc208067e98)
-//             /0/go/src/github.com/bugsnag/bugsnag-go/pan/main.go:22 +0x151
+//	main.(*foo).destruct(0xc208067e98)
+//	        /0/go/src/github.com/bugsnag/bugsnag-go/pan/main.go:22 +0x151
+//
+// Arguments can also be printed as `{0x4b0a20?, 0xc000012345?}` or `...`, and the file line
+// can be followed by frame pointers (`fp=0xc000 sp=0xc000 pc=0x47`) when GOTRACEBACK=system.
 func parsePanicFrame(name string, line string, createdBy bool) (*StackFrame, error) {
-	idx := strings.LastIndex(name, "(")
+	idx := argumentsIndex(name)
 	if idx == -1 && !createdBy {
 		return nil, Errorf("bugsnag.panicParser: Invalid line (no call): %s", name)
 	}
@@ -102,18 +311,20 @@ func parsePanicFrame(name string, line string, createdBy bool) (*StackFrame, err
 		return nil, Errorf("bugsnag.panicParser: Invalid line (no tab): %s", line)
 	}
 
+	line = strings.TrimPrefix(line, "\t")
+	for _, suffix := range []string{" fp=", " +"} {
+		if idx = strings.Index(line, suffix); idx > -1 {
+			line = line[:idx]
+		}
+	}
+
 	idx = strings.LastIndex(line, ":")
 	if idx == -1 {
 		return nil, Errorf("bugsnag.panicParser: Invalid line (no line number): %s", line)
 	}
-	file := line[1:idx]
+	file := line[:idx]
 
-	number := line[idx+1:]
-	if idx = strings.Index(number, " +"); idx > -1 {
-		number = number[:idx]
-	}
-
-	lno, err := strconv.ParseInt(number, 10, 32)
+	lno, err := strconv.ParseInt(line[idx+1:], 10, 32)
 	if err != nil {
 		return nil, Errorf("bugsnag.panicParser: Invalid line (bad line number): %s", line)
 	}
@@ -125,3 +336,24 @@ func parsePanicFrame(name string, line string, createdBy bool) (*StackFrame, err
 		Name:       name,
 	}, nil
 }
+
+// argumentsIndex returns the index of the parenthesis opening the argument list of a function
+// call like `main.(*T).f({0x1, 0x2}, 0x3)`, or -1 if there's none
+func argumentsIndex(call string) int {
+	if !strings.HasSuffix(call, ")") {
+		return -1
+	}
+	depth := 0
+	for i := len(call) - 1; i >= 0; i-- {
+		switch call[i] {
+		case ')':
+			depth++
+		case '(':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
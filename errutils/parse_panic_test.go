@@ -140,3 +140,238 @@ func TestParsePanic(t *testing.T) {
 		}
 	}
 }
+
+var fatalErrorOutput = `fatal error: all goroutines are asleep - deadlock!
+
+goroutine 1 [chan receive]:
+main.main()
+	/tmp/tb/main.go:38 +0x245
+exit status 2
+`
+
+var concurrentMapWrites = `fatal error: concurrent map writes
+
+goroutine 18 [running]:
+internal/runtime/maps.fatal({0x4ad0b8?, 0x0?})
+	/usr/local/go/src/runtime/panic.go:1053 +0x18
+main.main.func1()
+	/tmp/tb/main.go:34 +0x4f
+created by main.main in goroutine 1
+	/tmp/tb/main.go:34 +0x85
+
+goroutine 1 [runnable]:
+main.main()
+	/tmp/tb/main.go:35 +0x9a
+`
+
+var outOfMemory = `fatal error: runtime: out of memory
+
+runtime stack:
+runtime.throw({0x4b9a52?, 0x30000000?})
+	/usr/local/go/src/runtime/panic.go:1101 +0x48 fp=0x7ffc4d6e3a58 sp=0x7ffc4d6e3a28 pc=0x46ef08
+runtime.sysMapOS(0xc000400000, 0x3fc00000)
+	/usr/local/go/src/runtime/mem_linux.go:167 +0x11b fp=0x7ffc4d6e3a98 sp=0x7ffc4d6e3a58 pc=0x41233b
+
+goroutine 1 [running]:
+main.main()
+	/tmp/tb/main.go:12 +0x25
+`
+
+var recoveredRepanicked = `panic: boom
+	second line [recovered, repanicked]
+
+goroutine 1 [running]:
+main.main.func1()
+	/tmp/tb/main.go:17 +0x18
+panic({0x529c58?, 0x48ce28?})
+	/usr/local/go/src/runtime/panic.go:859 +0x125
+main.f({0x20f4f32301e0?, 0x475e93?}, {0x20f4f3278ea8?, 0x412b3d?}, 0x0?)
+	/tmp/tb/main.go:12 +0x25
+main.main()
+	/tmp/tb/main.go:18 +0x290
+`
+
+var nestedRecovered = `panic: first [recovered]
+	panic: second
+
+goroutine 1 [running]:
+main.main.func2()
+	/tmp/tb/main.go:20 +0x26
+panic({0x529c58?, 0x48ce38?})
+	/usr/local/go/src/runtime/panic.go:859 +0x125
+main.main()
+	/tmp/tb/main.go:21 +0x2f5
+`
+
+var signalLockedThread = `panic: runtime error: invalid memory address or nil pointer dereference
+[signal SIGSEGV: segmentation violation code=0x1 addr=0x0 pc=0x483354]
+
+goroutine 1 gp=0x38e70194a1e0 m=0 mp=0x53f4e0 [running, locked to thread]:
+panic({0x52acd8?, 0x539a80?})
+	/usr/local/go/src/runtime/panic.go:878 +0x159 fp=0x38e701994dd8 sp=0x38e701994d30 pc=0x476ad9
+runtime.panicmem(...)
+	/usr/local/go/src/runtime/panic.go:336
+main.main()
+	/tmp/tb/main.go:28 +0x154 fp=0x38e701994eb8 sp=0x38e701994e38 pc=0x483354
+
+goroutine 2 gp=0x38e70194a780 m=nil [force gc (idle)]:
+runtime.gopark(0x0?, 0x0?, 0x0?, 0x0?, 0x0?)
+	/usr/local/go/src/runtime/proc.go:474 +0xca fp=0x38e701984fa8 sp=0x38e701984f88 pc=0x476f0a
+created by runtime.init.7 in goroutine 1
+	/usr/local/go/src/runtime/proc.go:375 +0x1a
+`
+
+var elidedFrames = `panic: deep
+
+goroutine 1 [running]:
+main.main.func6(0x0?)
+	/tmp/tb/main.go:41 +0x3b
+main.main.func6(0x1?)
+	/tmp/tb/main.go:41 +0x22
+...102 frames elided...
+main.main.func6(0xc8?)
+	/tmp/tb/main.go:41 +0x22
+main.main()
+	/tmp/tb/main.go:43 +0x3a5
+...additional frames elided...
+`
+
+func TestParseTraceback(t *testing.T) {
+	cases := []struct {
+		name       string
+		output     string
+		message    string
+		fatal      bool
+		panics     []string
+		goroutines int
+		crashed    Goroutine
+	}{
+		{
+			name: "fatal error", output: fatalErrorOutput, message: "all goroutines are asleep - deadlock!",
+			fatal: true, panics: []string{"all goroutines are asleep - deadlock!"}, goroutines: 1,
+			crashed: Goroutine{ID: 1, State: "chan receive", Frames: []StackFrame{
+				{File: "/tmp/tb/main.go", LineNumber: 38, Name: "main", Package: "main"},
+			}},
+		},
+		{
+			name: "concurrent map writes", output: concurrentMapWrites, message: "concurrent map writes",
+			fatal: true, panics: []string{"concurrent map writes"}, goroutines: 2,
+			crashed: Goroutine{ID: 18, State: "running", Frames: []StackFrame{
+				{File: "/usr/local/go/src/runtime/panic.go", LineNumber: 1053, Name: "fatal", Package: "internal/runtime/maps"},
+				{File: "/tmp/tb/main.go", LineNumber: 34, Name: "main.func1", Package: "main"},
+			}, CreatedBy: &StackFrame{File: "/tmp/tb/main.go", LineNumber: 34, Name: "main", Package: "main"},
+				CreatedByGoroutine: 1},
+		},
+		{
+			name: "runtime stack", output: outOfMemory, message: "runtime: out of memory",
+			fatal: true, panics: []string{"runtime: out of memory"}, goroutines: 1,
+			crashed: Goroutine{ID: 1, State: "running", Frames: []StackFrame{
+				{File: "/tmp/tb/main.go", LineNumber: 12, Name: "main", Package: "main"},
+			}},
+		},
+		{
+			name: "recovered and repanicked", output: recoveredRepanicked, message: "boom\nsecond line",
+			panics: []string{"boom\nsecond line"}, goroutines: 1,
+			crashed: Goroutine{ID: 1, State: "running", Frames: []StackFrame{
+				{File: "/tmp/tb/main.go", LineNumber: 17, Name: "main.func1", Package: "main"},
+				{File: "/usr/local/go/src/runtime/panic.go", LineNumber: 859, Name: "panic", Package: ""},
+				{File: "/tmp/tb/main.go", LineNumber: 12, Name: "f", Package: "main"},
+				{File: "/tmp/tb/main.go", LineNumber: 18, Name: "main", Package: "main"},
+			}},
+		},
+		{
+			name: "nested recovered", output: nestedRecovered, message: "second",
+			panics: []string{"first", "second"}, goroutines: 1,
+			crashed: Goroutine{ID: 1, State: "running", Frames: []StackFrame{
+				{File: "/tmp/tb/main.go", LineNumber: 20, Name: "main.func2", Package: "main"},
+				{File: "/usr/local/go/src/runtime/panic.go", LineNumber: 859, Name: "panic", Package: ""},
+				{File: "/tmp/tb/main.go", LineNumber: 21, Name: "main", Package: "main"},
+			}},
+		},
+		{
+			name: "signal and locked to thread", output: signalLockedThread,
+			message: "runtime error: invalid memory address or nil pointer dereference",
+			panics:  []string{"runtime error: invalid memory address or nil pointer dereference"}, goroutines: 2,
+			crashed: Goroutine{ID: 1, State: "running, locked to thread", Frames: []StackFrame{
+				{File: "/usr/local/go/src/runtime/panic.go", LineNumber: 878, Name: "panic", Package: ""},
+				{File: "/usr/local/go/src/runtime/panic.go", LineNumber: 336, Name: "panicmem", Package: "runtime"},
+				{File: "/tmp/tb/main.go", LineNumber: 28, Name: "main", Package: "main"},
+			}},
+		},
+		{
+			name: "elided frames", output: elidedFrames, message: "deep",
+			panics: []string{"deep"}, goroutines: 1,
+			crashed: Goroutine{ID: 1, State: "running", Frames: []StackFrame{
+				{File: "/tmp/tb/main.go", LineNumber: 41, Name: "main.func6", Package: "main"},
+				{File: "/tmp/tb/main.go", LineNumber: 41, Name: "main.func6", Package: "main"},
+				{File: "/tmp/tb/main.go", LineNumber: 41, Name: "main.func6", Package: "main"},
+				{File: "/tmp/tb/main.go", LineNumber: 43, Name: "main", Package: "main"},
+			}, FramesElided: true},
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			traceback, err := ParseTraceback(tt.output)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if traceback.Message != tt.message {
+				t.Errorf("Wrong message: %q", traceback.Message)
+			}
+			if traceback.Fatal != tt.fatal {
+				t.Errorf("Wrong fatal: %v", traceback.Fatal)
+			}
+			if !reflect.DeepEqual(traceback.Panics, tt.panics) {
+				t.Errorf("Wrong panics: %q", traceback.Panics)
+			}
+			if len(traceback.Goroutines) != tt.goroutines {
+				t.Errorf("Wrong number of goroutines: %d", len(traceback.Goroutines))
+			}
+			if crashed := traceback.Crashed(); !reflect.DeepEqual(*crashed, tt.crashed) {
+				t.Errorf("Wrong crashed goroutine: %#v", *crashed)
+			}
+		})
+	}
+}
+
+func TestParseTraceback_signal(t *testing.T) {
+	traceback, err := ParseTraceback(signalLockedThread)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &Signal{Name: "SIGSEGV", Description: "segmentation violation", Code: "0x1", Addr: "0x0", PC: "0x483354"}
+	if !reflect.DeepEqual(traceback.Signal, expected) {
+		t.Errorf("Wrong signal: %#v", traceback.Signal)
+	}
+	g := traceback.Goroutines[1]
+	if g.ID != 2 || g.State != "force gc (idle)" || g.CreatedByGoroutine != 1 || g.CreatedBy.Name != "init.7" {
+		t.Errorf("Wrong goroutine: %#v", g)
+	}
+}
+
+func TestParsePanic_fatalError(t *testing.T) {
+	Err, err := ParsePanic(concurrentMapWrites)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if Err.TypeName() != "fatal error" {
+		t.Errorf("Wrong type: %s", Err.TypeName())
+	}
+	if Err.Error() != "concurrent map writes" {
+		t.Errorf("Wrong message: %s", Err.Error())
+	}
+	// frames of the goroutine and the frame that created it
+	if len(Err.StackFrames()) != 3 {
+		t.Errorf("Wrong stack: %#v", Err.StackFrames())
+	}
+}
+
+func TestParsePanic_invalid(t *testing.T) {
+	if _, err := ParsePanic("listening on :8080\n"); err == nil {
+		t.Errorf("expected an error for output without a panic")
+	}
+	if _, err := ParsePanic("panic: hello!\n"); err == nil {
+		t.Errorf("expected an error for output without goroutines")
+	}
+}
@@ -0,0 +1,184 @@
+package errutils
+
+import (
+	"strconv"
+	"strings"
+)
+
+// RaceReport is a data race reported by the race detector (`WARNING: DATA RACE`)
+type RaceReport struct {
+	// Current is the access that triggered the report
+	Current RaceAccess
+	// Previous is the access it conflicts with
+	Previous RaceAccess
+	// Goroutines holds the goroutines involved in the race, with the stack that created them
+	Goroutines []RaceGoroutine
+}
+
+// RaceAccess is one of the two conflicting memory accesses of a data race
+type RaceAccess struct {
+	// Operation is the kind of access, like `read`, `write` or `atomic write`
+	Operation string
+	Addr      string
+	// Goroutine is the ID of the goroutine doing the access (1 for the main goroutine)
+	Goroutine int
+	Frames    []StackFrame
+}
+
+// RaceGoroutine is a goroutine involved in a data race
+type RaceGoroutine struct {
+	ID int
+	// State is `running` or `finished`
+	State     string
+	CreatedAt []StackFrame
+}
+
+const raceSeparator = "=================="
+
+// ParseRaceReport parses all the data races reported by the race detector in the output of a go program.
+// A report looks like:
+//
+//	==================
+//	WARNING: DATA RACE
+//	Write at 0x00c000018198 by goroutine 10:
+//	  main.main.func3()
+//	      /tmp/race/main.go:20 +0x2e
+//
+//	Previous write at 0x00c000018198 by main goroutine:
+//	  main.main()
+//	      /tmp/race/main.go:21 +0x284
+//
+//	Goroutine 10 (running) created at:
+//	  main.main()
+//	      /tmp/race/main.go:20 +0x279
+//	==================
+func ParseRaceReport(text string) ([]RaceReport, error) {
+	lines := strings.Split(strings.Replace(text, "\r\n", "\n", -1), "\n")
+
+	var reports []RaceReport
+	for i := 0; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) != "WARNING: DATA RACE" {
+			continue
+		}
+		report, next, err := parseRaceReport(lines, i+1)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, *report)
+		i = next
+	}
+
+	if len(reports) == 0 {
+		return nil, Errorf("raceParser: Invalid output (no data race): %s", text)
+	}
+	return reports, nil
+}
+
+// parseRaceReport parses the blocks of a data race report starting at line `i`, and returns
+// the index of its last line
+func parseRaceReport(lines []string, i int) (*RaceReport, int, error) {
+	report := &RaceReport{}
+	accesses := 0
+	for ; i < len(lines); i++ {
+		header := strings.TrimSpace(lines[i])
+		if header == raceSeparator || header == "WARNING: DATA RACE" {
+			break
+		}
+		if header == "" || !strings.HasSuffix(header, ":") {
+			continue
+		}
+
+		frames, next, err := parseRaceFrames(lines, i+1)
+		if err != nil {
+			return nil, i, err
+		}
+
+		if strings.HasPrefix(header, "Goroutine ") {
+			g, err := parseRaceGoroutine(header)
+			if err != nil {
+				return nil, i, err
+			}
+			g.CreatedAt = frames
+			report.Goroutines = append(report.Goroutines, *g)
+		} else if strings.Contains(header, " at ") && strings.Contains(header, " by ") {
+			access, err := parseRaceAccess(header)
+			if err != nil {
+				return nil, i, err
+			}
+			access.Frames = frames
+			if accesses == 0 {
+				report.Current = *access
+			} else {
+				report.Previous = *access
+			}
+			accesses++
+		}
+		i = next
+	}
+
+	if accesses != 2 {
+		return nil, i, Errorf("raceParser: Invalid report (expected two accesses, got %d)", accesses)
+	}
+	return report, i, nil
+}
+
+// parseRaceAccess parses a line like `Previous write at 0x00c000018198 by goroutine 8:` or
+// `Read at 0x00c00001a078 by main goroutine:`
+func parseRaceAccess(header string) (*RaceAccess, error) {
+	header = strings.TrimSuffix(header, ":")
+	header = strings.TrimPrefix(header, "Previous ")
+	atIdx := strings.Index(header, " at ")
+	byIdx := strings.LastIndex(header, " by ")
+	if atIdx == -1 || byIdx < atIdx {
+		return nil, Errorf("raceParser: Invalid line (bad access): %s", header)
+	}
+
+	access := &RaceAccess{
+		Operation: strings.ToLower(header[:atIdx]),
+		Addr:      header[atIdx+len(" at ") : byIdx],
+		Goroutine: 1,
+	}
+	if goroutine := header[byIdx+len(" by "):]; goroutine != "main goroutine" {
+		id, err := strconv.Atoi(strings.TrimPrefix(goroutine, "goroutine "))
+		if err != nil {
+			return nil, Errorf("raceParser: Invalid line (bad goroutine id): %s", header)
+		}
+		access.Goroutine = id
+	}
+	return access, nil
+}
+
+// parseRaceGoroutine parses a line like `Goroutine 10 (running) created at:`
+func parseRaceGoroutine(header string) (*RaceGoroutine, error) {
+	fields := strings.Fields(header)
+	if len(fields) < 3 {
+		return nil, Errorf("raceParser: Invalid line (bad goroutine): %s", header)
+	}
+	id, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return nil, Errorf("raceParser: Invalid line (bad goroutine id): %s", header)
+	}
+	return &RaceGoroutine{ID: id, State: strings.Trim(fields[2], "()")}, nil
+}
+
+// parseRaceFrames parses the frames starting at line `i`, indented with spaces, and returns them
+// with the index of their last line
+func parseRaceFrames(lines []string, i int) ([]StackFrame, int, error) {
+	var frames []StackFrame
+	for ; i+1 < len(lines); i += 2 {
+		name := lines[i]
+		if !strings.HasPrefix(name, "  ") || strings.TrimSpace(name) == "" {
+			break
+		}
+		if strings.TrimSpace(name) == "[failed to restore the stack]" {
+			i--
+			continue
+		}
+		frame, err := parsePanicFrame(strings.TrimSpace(name), "\t"+strings.TrimSpace(lines[i+1]), false)
+		if err != nil {
+			return nil, i, err
+		}
+		frames = append(frames, *frame)
+	}
+	return frames, i - 1, nil
+}
@@ -0,0 +1,86 @@
+package errutils
+
+import (
+	"reflect"
+	"testing"
+)
+
+var raceOutput = `==================
+WARNING: DATA RACE
+Read at 0x00c00001a078 by goroutine 9:
+  main.main.func2()
+      /tmp/race/main.go:17 +0x9b
+
+Previous write at 0x00c00001a078 by goroutine 8:
+  main.(*S).inc()
+      /tmp/race/main.go:10 +0x9e
+  main.main.func1()
+      /tmp/race/main.go:16 +0x12
+
+Goroutine 9 (running) created at:
+  main.main()
+      /tmp/race/main.go:17 +0x1e4
+
+Goroutine 8 (finished) created at:
+  main.main()
+      /tmp/race/main.go:16 +0x13c
+==================
+1
+2
+==================
+WARNING: DATA RACE
+Write at 0x00c000018198 by goroutine 10:
+  main.main.func3()
+      /tmp/race/main.go:20 +0x2e
+
+Previous write at 0x00c000018198 by main goroutine:
+  main.main()
+      /tmp/race/main.go:21 +0x284
+
+Goroutine 10 (running) created at:
+  main.main()
+      /tmp/race/main.go:20 +0x279
+==================
+Found 2 data race(s)
+`
+
+func TestParseRaceReport(t *testing.T) {
+	reports, err := ParseRaceReport(raceOutput)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != 2 {
+		t.Fatalf("Wrong number of reports: %d", len(reports))
+	}
+
+	expected := RaceReport{
+		Current: RaceAccess{Operation: "read", Addr: "0x00c00001a078", Goroutine: 9, Frames: []StackFrame{
+			{File: "/tmp/race/main.go", LineNumber: 17, Name: "main.func2", Package: "main"},
+		}},
+		Previous: RaceAccess{Operation: "write", Addr: "0x00c00001a078", Goroutine: 8, Frames: []StackFrame{
+			{File: "/tmp/race/main.go", LineNumber: 10, Name: "(*S).inc", Package: "main"},
+			{File: "/tmp/race/main.go", LineNumber: 16, Name: "main.func1", Package: "main"},
+		}},
+		Goroutines: []RaceGoroutine{
+			{ID: 9, State: "running", CreatedAt: []StackFrame{
+				{File: "/tmp/race/main.go", LineNumber: 17, Name: "main", Package: "main"},
+			}},
+			{ID: 8, State: "finished", CreatedAt: []StackFrame{
+				{File: "/tmp/race/main.go", LineNumber: 16, Name: "main", Package: "main"},
+			}},
+		},
+	}
+	if !reflect.DeepEqual(reports[0], expected) {
+		t.Errorf("Wrong report: %#v", reports[0])
+	}
+
+	if reports[1].Previous.Goroutine != 1 || reports[1].Current.Operation != "write" || len(reports[1].Goroutines) != 1 {
+		t.Errorf("Wrong report: %#v", reports[1])
+	}
+}
+
+func TestParseRaceReport_invalid(t *testing.T) {
+	if _, err := ParseRaceReport("PASS\nok\n"); err == nil {
+		t.Errorf("expected an error for output without data races")
+	}
+}
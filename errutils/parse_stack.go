@@ -0,0 +1,65 @@
+package errutils
+
+import (
+	"strings"
+)
+
+// ParseStack parses the frames of a stack printed by runtime/debug.Stack(), like:
+//
+//	goroutine 1 [running]:
+//	runtime/debug.Stack()
+//		/usr/local/go/src/runtime/debug/stack.go:26 +0x5e
+//	main.main()
+//		/tmp/main.go:8 +0x1d
+//
+// The goroutine header is optional, and anything before it or before the first frame is ignored, like
+// the message and prefixes of a log line. If there are several goroutines, the frames of the first one
+// are returned, including the frame that created it.
+func ParseStack(text string) ([]StackFrame, error) {
+	lines := strings.Split(strings.Replace(strings.TrimSpace(text), "\r\n", "\n", -1), "\n")
+
+	g := &Goroutine{}
+	var err error
+	for i, line := range lines {
+		if idx := strings.Index(line, "goroutine "); idx != -1 && isGoroutineHeader(line[idx:]) {
+			lines[i] = line[idx:]
+			g, _, err = parseGoroutine(lines, i)
+			break
+		}
+		if isFrameStart(lines, i) {
+			_, err = parseGoroutineFrames(lines, i, g)
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	frames := g.Frames
+	if g.CreatedBy != nil {
+		frames = append(frames, *g.CreatedBy)
+	}
+	if len(frames) == 0 {
+		return nil, Errorf("stackParser: Invalid stack (no frames): %s", text)
+	}
+	return frames, nil
+}
+
+// isFrameStart returns whether line `i` is the function call of a frame, followed by its file line
+func isFrameStart(lines []string, i int) bool {
+	return i+1 < len(lines) && strings.HasPrefix(lines[i+1], "\t") && argumentsIndex(lines[i]) != -1
+}
+
+// ParseGoroutineDump parses all the goroutines of a dump printed by runtime.Stack(buf, true), or by
+// the goroutine profile of net/http/pprof with debug=2. Output that is not a goroutine is ignored.
+func ParseGoroutineDump(text string) ([]Goroutine, error) {
+	lines := strings.Split(strings.Replace(text, "\r\n", "\n", -1), "\n")
+	goroutines, err := parseGoroutines(lines)
+	if err != nil {
+		return nil, err
+	}
+	if len(goroutines) == 0 {
+		return nil, Errorf("stackParser: Invalid dump (no goroutines): %s", text)
+	}
+	return goroutines, nil
+}
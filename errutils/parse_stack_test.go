@@ -0,0 +1,95 @@
+package errutils
+
+import (
+	"reflect"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"testing"
+)
+
+var debugStack = `goroutine 1 [running]:
+runtime/debug.Stack()
+	/usr/local/go/src/runtime/debug/stack.go:26 +0x5e
+main.main()
+	/tmp/main.go:8 +0x1d
+`
+
+func TestParseStack(t *testing.T) {
+	expected := []StackFrame{
+		{File: "/usr/local/go/src/runtime/debug/stack.go", LineNumber: 26, Name: "Stack", Package: "runtime/debug"},
+		{File: "/tmp/main.go", LineNumber: 8, Name: "main", Package: "main"},
+	}
+
+	for name, stack := range map[string]string{
+		"with header":    debugStack,
+		"without header": strings.SplitN(debugStack, "\n", 2)[1],
+		"indented":       "\n  " + debugStack + "\n\n",
+		"log prefix":     "2024/01/02 15:04:05 [ERROR] worker stalled: " + debugStack,
+		"log line":       "2024-01-02T15:04:05Z level=error msg=\"worker stalled\"\n" + debugStack,
+		"log line without header": "2024-01-02T15:04:05Z level=error msg=\"worker stalled\"\n" +
+			strings.SplitN(debugStack, "\n", 2)[1],
+	} {
+		t.Run(name, func(t *testing.T) {
+			frames, err := ParseStack(stack)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(frames, expected) {
+				t.Errorf("Wrong stack: %#v", frames)
+			}
+		})
+	}
+}
+
+func TestParseStack_debugStack(t *testing.T) {
+	frames, err := ParseStack(string(debug.Stack()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, frame := range frames {
+		if frame.Name == "TestParseStack_debugStack" && strings.HasSuffix(frame.File, "parse_stack_test.go") {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Stack does not contain the test function: %#v", frames)
+	}
+}
+
+func TestParseStack_invalid(t *testing.T) {
+	if _, err := ParseStack("not a stack"); err == nil {
+		t.Errorf("expected an error for text without frames")
+	}
+}
+
+func TestParseGoroutineDump(t *testing.T) {
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		<-done
+	}()
+
+	buf := make([]byte, 1<<16)
+	buf = buf[:runtime.Stack(buf, true)]
+	goroutines, err := ParseGoroutineDump(string(buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(goroutines) < 2 {
+		t.Fatalf("Wrong number of goroutines: %d", len(goroutines))
+	}
+	if !goroutines[0].Running() {
+		t.Errorf("Expected the first goroutine to be running: %#v", goroutines[0])
+	}
+	created := false
+	for _, g := range goroutines {
+		if g.CreatedBy != nil && g.CreatedBy.Name == "TestParseGoroutineDump" && g.CreatedByGoroutine == goroutines[0].ID {
+			created = true
+		}
+	}
+	if !created {
+		t.Errorf("Dump does not contain the goroutine created by the test: %#v", goroutines)
+	}
+}
@@ -2,13 +2,80 @@ package periskop
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
 	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
+	"time"
 )
 
+const (
+	// DefaultPushTimeout is the timeout of the pushes made with the default HTTP client
+	DefaultPushTimeout = 10 * time.Second
+	// DefaultRetryBackoff is the default wait before retrying a failed push, doubled on every retry
+	DefaultRetryBackoff = 500 * time.Millisecond
+	// DefaultMaxRetryBackoff is the default maximum wait between retries of a push
+	DefaultMaxRetryBackoff = 30 * time.Second
+	// maxPushErrorBody is the maximum size of the body of the gateway kept in a PushError
+	maxPushErrorBody = 4 << 10
+)
+
+var defaultPushClient = &http.Client{Timeout: DefaultPushTimeout}
+
+// PushOptions configures how errors are pushed to a pushgateway
+type PushOptions struct {
+	// Client used to push, defaults to a client with DefaultPushTimeout
+	Client *http.Client
+	// MaxRetries is the number of times a push is retried after a network error or a 429/5xx response
+	MaxRetries int
+	// RetryBackoff is the wait before the first retry, doubled on every retry up to MaxRetryBackoff
+	RetryBackoff    time.Duration
+	MaxRetryBackoff time.Duration
+	// BearerToken is sent in the Authorization header when not empty
+	BearerToken string
+	// BasicAuthUser and BasicAuthPassword are used for basic auth when BasicAuthUser is not empty
+	BasicAuthUser     string
+	BasicAuthPassword string
+	// Headers are custom headers added to the push requests
+	Headers map[string]string
+	// Gzip compresses the pushed payload
+	Gzip bool
+	// Delta pushes only the errors collected since the last acknowledged push (see PushToGatewayWithContext)
+	Delta bool
+	// SpoolDir is a directory where failed pushes are kept, to be pushed before the next push
+	SpoolDir string
+	// SpoolMaxSize and SpoolMaxAge limit the pushes kept in the spool, dropping the oldest ones first.
+	// They default to DefaultSpoolMaxSize and DefaultSpoolMaxAge.
+	SpoolMaxSize int64
+	SpoolMaxAge  time.Duration
+
+	// signer is the signer of the exporter making the push
+	signer *Signer
+}
+
+// PushError is returned when the pushgateway doesn't accept a push
+type PushError struct {
+	StatusCode int
+	// Body is the beginning of the response of the gateway
+	Body string
+}
+
+func (e *PushError) Error() string {
+	return fmt.Sprintf("pushgateway responded with status %d: %s", e.StatusCode, e.Body)
+}
+
+// retryable returns whether the push could succeed if retried
+func (e *PushError) retryable() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
 // ErrorExporter exposes collected errors
 type ErrorExporter struct {
 	collector *ErrorCollector
+	signer    *Signer
 }
 
 // NewErrorExporter creates a new ErrorExporter
@@ -19,7 +86,12 @@ func NewErrorExporter(collector *ErrorCollector) ErrorExporter {
 }
 
 func (e *ErrorExporter) export() ([]byte, error) {
-	payload := e.collector.getAggregatedErrors()
+	return e.exportFiltered(errorsFilter{})
+}
+
+// exportFiltered exports the collected errors selected by `filter` in json format
+func (e *ErrorExporter) exportFiltered(filter errorsFilter) ([]byte, error) {
+	payload := e.collector.getAggregatedErrors(filter)
 	res, err := json.Marshal(payload)
 	if err != nil {
 		return []byte{}, err
@@ -27,6 +99,12 @@ func (e *ErrorExporter) export() ([]byte, error) {
 	return res, nil
 }
 
+// SetSigner signs the payloads exposed by the handler of the exporter and pushed to pushgateways,
+// in the SignatureHeader header. It must be set before creating the handler.
+func (e *ErrorExporter) SetSigner(signer *Signer) {
+	e.signer = signer
+}
+
 // Export exports all collected errors in json format
 func (e *ErrorExporter) Export() (string, error) {
 	res, err := e.export()
@@ -35,12 +113,146 @@ func (e *ErrorExporter) Export() (string, error) {
 
 // PushToGateway pushes all collected errors to the pushgateway specified by `addr`
 func (e *ErrorExporter) PushToGateway(addr string) error {
+	return e.PushToGatewayWithContext(context.Background(), addr, PushOptions{})
+}
+
+// PushToGatewayWithContext pushes all collected errors to the pushgateway specified by `addr`, retrying
+// failed pushes as configured in `opts`. A *PushError is returned when the gateway rejects the push.
+//
+// With `opts.Delta`, only the errors collected since the last push acknowledged by the gateway are
+// pushed, with their count increments. Delta pushes carry a sequence number and an idempotency key, and
+// a push that failed is sent again unchanged before any newer errors, so the gateway can discard the
+// pushes it already counted. What was pushed is tracked by the collector, so it's shared by all its exporters.
+//
+// With `opts.SpoolDir`, pushes that fail are written to the spool directory, and the pushes in the spool
+// are pushed before the next push, made by this or a later process. The error of the failed push is
+// returned even if it was spooled.
+func (e *ErrorExporter) PushToGatewayWithContext(ctx context.Context, addr string, opts PushOptions) error {
+	opts.signer = e.signer
+	send := func(ctx context.Context, data []byte, idempotencyKey string) error {
+		return pushWithRetries(ctx, addr+"/errors", data, idempotencyKey, opts)
+	}
+	return e.push(ctx, addr, send, opts)
+}
+
+// sendFunc sends a push to a pushgateway
+type sendFunc func(ctx context.Context, data []byte, idempotencyKey string) error
+
+// push pushes all collected errors with `send`. Delta pushes sent with the same `deltaKey` share
+// the state of what was acknowledged.
+func (e *ErrorExporter) push(ctx context.Context, deltaKey string, send sendFunc, opts PushOptions) error {
+	if opts.Delta {
+		return e.pushDelta(ctx, deltaKey, send, opts)
+	}
 	exportedData, err := e.export()
-	if err == nil {
-		_, err := http.Post(addr+"/errors", "application/json", bytes.NewBuffer(exportedData))
+	if err != nil {
+		return err
+	}
+	_, err = pushOrSpool(ctx, send, exportedData, "", opts)
+	return err
+}
+
+// pushWithRetries pushes `data` to `url`, retrying with exponential backoff
+func pushWithRetries(ctx context.Context, url string, data []byte, idempotencyKey string, opts PushOptions) error {
+	backoff := opts.RetryBackoff
+	if backoff <= 0 {
+		backoff = DefaultRetryBackoff
+	}
+	maxBackoff := opts.MaxRetryBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = DefaultMaxRetryBackoff
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := push(ctx, url, data, idempotencyKey, opts)
 		if err == nil {
 			return nil
 		}
+		if pushErr, ok := err.(*PushError); ok && !pushErr.retryable() {
+			return err
+		}
+		if attempt >= opts.MaxRetries || ctx.Err() != nil {
+			return err
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
 	}
-	return err
+}
+
+// push makes a single push request
+func push(ctx context.Context, url string, data []byte, idempotencyKey string, opts PushOptions) error {
+	body, err := pushBody(data, opts.Gzip)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, url, body)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	if opts.Gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	if idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+	if opts.signer != nil {
+		// the uncompressed payload is signed
+		req.Header.Set(SignatureHeader, opts.signer.Sign(data, time.Now()))
+	}
+	for name, value := range opts.Headers {
+		req.Header.Set(name, value)
+	}
+	if opts.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+opts.BearerToken)
+	} else if opts.BasicAuthUser != "" {
+		req.SetBasicAuth(opts.BasicAuthUser, opts.BasicAuthPassword)
+	}
+
+	client := opts.Client
+	if client == nil {
+		client = defaultPushClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxPushErrorBody))
+	if err != nil {
+		return err
+	}
+	// drain the rest of the body, so the connection can be reused
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &PushError{StatusCode: resp.StatusCode, Body: string(respBody)}
+	}
+	return nil
+}
+
+func pushBody(data []byte, compress bool) (io.Reader, error) {
+	if !compress {
+		return bytes.NewReader(data), nil
+	}
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return &buf, nil
 }
@@ -1,10 +1,15 @@
 package periskop
 
 import (
+	"compress/gzip"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -32,6 +37,13 @@ func TestExporter_Export(t *testing.T) {
 	c := NewErrorCollector()
 	uuid, _ := uuid.Parse("5d9893c6-51d6-11ea-8aad-f894c260afe5")
 	c.uuid = uuid
+	c.target = Target{
+		ServiceName: "checkout",
+		Hostname:    "checkout-1",
+		PID:         42,
+		GoVersion:   "go1.22.0",
+		Labels:      map[string]string{"region": "eu-west-1"},
+	}
 	errWithContext := ErrorWithContext{
 		Error: ErrorInstance{
 			Class:      errors.New("testing").Error(),
@@ -57,6 +69,13 @@ func TestExporter_Export(t *testing.T) {
 	}
 	var expected = `{
 		"target_uuid": "5d9893c6-51d6-11ea-8aad-f894c260afe5",
+		"target": {
+			"service_name": "checkout",
+			"hostname": "checkout-1",
+			"pid": 42,
+			"go_version": "go1.22.0",
+			"labels": {"region": "eu-west-1"}
+		},
 		"aggregated_errors":[
 		  {
 			"aggregation_key":"test",
@@ -107,12 +126,123 @@ func TestExporter_Export(t *testing.T) {
 }
 
 func TestExporter_Push(t *testing.T) {
+	var pushed payload
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/errors" {
+			t.Errorf("incorrect path, got %s", r.URL.Path)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&pushed); err != nil {
+			t.Errorf("error decoding pushed errors: %v", err)
+		}
+	}))
+	defer gateway.Close()
+
 	c := NewErrorCollector()
 	errTest := errors.New("testing")
 	c.ReportError(errTest)
 	e := NewErrorExporter(&c)
-	err := e.PushToGateway("http://localhost:7878")
+	err := e.PushToGateway(gateway.URL)
+	if err != nil {
+		t.Errorf("error pushing exceptions: %v", err)
+	}
+	if len(pushed.AggregatedErrors) != 1 {
+		t.Errorf("expected one element")
+	}
+}
+
+func TestExporter_PushToGatewayWithContext(t *testing.T) {
+	var attempts int32
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			t.Errorf("incorrect authorization header, got %s", r.Header.Get("Authorization"))
+		}
+		if r.Header.Get("X-Team") != "payments" {
+			t.Errorf("expected custom header")
+		}
+		if r.Header.Get("Content-Encoding") != "gzip" {
+			t.Errorf("expected gzip content encoding")
+		}
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			t.Fatalf("error reading gzip body: %v", err)
+		}
+		var p payload
+		if err := json.NewDecoder(gz).Decode(&p); err != nil {
+			t.Errorf("error decoding pushed errors: %v", err)
+		}
+		if atomic.AddInt32(&attempts, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer gateway.Close()
+
+	c := NewErrorCollector()
+	c.ReportError(errors.New("testing"))
+	e := NewErrorExporter(&c)
+	err := e.PushToGatewayWithContext(context.Background(), gateway.URL, PushOptions{
+		MaxRetries:   2,
+		RetryBackoff: time.Millisecond,
+		BearerToken:  "secret",
+		Headers:      map[string]string{"X-Team": "payments"},
+		Gzip:         true,
+	})
 	if err != nil {
 		t.Errorf("error pushing exceptions: %v", err)
 	}
+	if atomic.LoadInt32(&attempts) != 3 {
+		t.Errorf("expected 3 attempts, got %d", attempts)
+	}
+}
+
+func TestExporter_PushError(t *testing.T) {
+	var attempts int32
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		user, password, ok := r.BasicAuth()
+		if !ok || user != "periskop" || password != "secret" {
+			t.Errorf("expected basic auth")
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte("invalid payload"))
+	}))
+	defer gateway.Close()
+
+	c := NewErrorCollector()
+	e := NewErrorExporter(&c)
+	err := e.PushToGatewayWithContext(context.Background(), gateway.URL, PushOptions{
+		MaxRetries:        3,
+		RetryBackoff:      time.Millisecond,
+		BasicAuthUser:     "periskop",
+		BasicAuthPassword: "secret",
+	})
+	pushErr, ok := err.(*PushError)
+	if !ok {
+		t.Fatalf("expected a PushError, got %v", err)
+	}
+	if pushErr.StatusCode != http.StatusBadRequest || pushErr.Body != "invalid payload" {
+		t.Errorf("incorrect push error, got %v", pushErr)
+	}
+	if atomic.LoadInt32(&attempts) != 1 {
+		t.Errorf("expected client errors not to be retried")
+	}
+}
+
+func TestExporter_PushCanceled(t *testing.T) {
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer gateway.Close()
+
+	c := NewErrorCollector()
+	e := NewErrorExporter(&c)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := e.PushToGatewayWithContext(ctx, gateway.URL, PushOptions{MaxRetries: 10, RetryBackoff: time.Hour})
+	if err == nil {
+		t.Errorf("expected an error")
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("expected retries to stop when the context is done")
+	}
 }
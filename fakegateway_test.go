@@ -0,0 +1,78 @@
+package periskop
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+)
+
+// fakeGateway is a pushgateway for the push tests, which records the payloads pushed to it.
+// The tests of this package can't use periskoptest/gateway, as it imports this package.
+type fakeGateway struct {
+	*httptest.Server
+	mux      sync.Mutex
+	payloads []payload
+	// down makes the gateway reject the pushes without recording them
+	down bool
+	// lost makes the gateway record the pushes but fail, as if the response was lost
+	lost bool
+}
+
+func newFakeGateway() *fakeGateway {
+	g := &fakeGateway{}
+	g.Server = httptest.NewServer(g)
+	return g
+}
+
+func (g *fakeGateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+	if g.down {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	var body io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		body = gz
+	}
+	var p payload
+	if err := json.NewDecoder(body).Decode(&p); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if p.IdempotencyKey != r.Header.Get("Idempotency-Key") {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	g.payloads = append(g.payloads, p)
+	if g.lost {
+		w.WriteHeader(http.StatusBadGateway)
+	}
+}
+
+func (g *fakeGateway) setDown(down bool) {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+	g.down = down
+}
+
+func (g *fakeGateway) setLost(lost bool) {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+	g.lost = lost
+}
+
+// pushed gets the payloads pushed to the gateway
+func (g *fakeGateway) pushed() []payload {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+	return append([]payload(nil), g.payloads...)
+}
@@ -0,0 +1,133 @@
+package periskop
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// severityRanks orders the severities, from the least to the most severe
+var severityRanks = map[Severity]int{
+	SeverityInfo:     1,
+	SeverityWarning:  2,
+	SeverityError:    3,
+	SeverityCritical: 4,
+}
+
+// errorsFilter selects the aggregated errors exported by the handler. The zero value selects all the errors.
+type errorsFilter struct {
+	// severity selects the errors with this severity, or with this severity or higher if minSeverity is set
+	severity    Severity
+	minSeverity bool
+	// since selects the errors with instances newer than this time, dropping the older instances
+	since     time.Time
+	key       string
+	keyPrefix string
+	// class selects the errors whose first instance has this class, like the class label of the metrics
+	class string
+	// limit selects the errors with the highest counts when greater than zero, no limit when zero
+	limit int
+	// noInstances drops the instances of the errors, to only export their counts
+	noInstances bool
+}
+
+// parseErrorsFilter parses a filter from the query parameters `severity` (or `severity>` for a minimum
+// severity, as in `severity>=warning`), `since` (RFC 3339 or unix timestamp), `key`, `key_prefix`,
+// `class`, `limit` and `instances=0`
+func parseErrorsFilter(query url.Values) (errorsFilter, error) {
+	var f errorsFilter
+	if severity := query.Get("severity>"); severity != "" {
+		f.severity = Severity(severity)
+		f.minSeverity = true
+	} else if severity := query.Get("severity"); severity != "" {
+		f.severity = Severity(severity)
+	}
+	if _, ok := severityRanks[f.severity]; f.severity != "" && !ok {
+		return f, fmt.Errorf("invalid severity %q", f.severity)
+	}
+
+	if since := query.Get("since"); since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			unix, unixErr := strconv.ParseInt(since, 10, 64)
+			if unixErr != nil {
+				return f, fmt.Errorf("invalid since %q: expected an RFC 3339 or unix timestamp", since)
+			}
+			t = time.Unix(unix, 0)
+		}
+		f.since = t
+	}
+
+	f.key = query.Get("key")
+	f.keyPrefix = query.Get("key_prefix")
+	f.class = query.Get("class")
+
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 {
+			return f, fmt.Errorf("invalid limit %q: expected a positive number", limit)
+		}
+		f.limit = n
+	}
+
+	if instances := query.Get("instances"); instances != "" {
+		f.noInstances = instances == "0" || instances == "false"
+	}
+	return f, nil
+}
+
+// filter gets the aggregated error selected by the filter, copying only the selected instances
+func (f errorsFilter) filter(aggregatedErr *aggregatedError) (aggregatedError, bool) {
+	if f.key != "" && aggregatedErr.AggregationKey != f.key {
+		return aggregatedError{}, false
+	}
+	if f.keyPrefix != "" && !strings.HasPrefix(aggregatedErr.AggregationKey, f.keyPrefix) {
+		return aggregatedError{}, false
+	}
+	if f.severity != "" {
+		if f.minSeverity && severityRanks[aggregatedErr.Severity] < severityRanks[f.severity] {
+			return aggregatedError{}, false
+		}
+		if !f.minSeverity && aggregatedErr.Severity != f.severity {
+			return aggregatedError{}, false
+		}
+	}
+	if f.class != "" && aggregatedErr.class != f.class {
+		return aggregatedError{}, false
+	}
+
+	filtered := *aggregatedErr
+	if !f.since.IsZero() {
+		var latestErrors []ErrorWithContext
+		for _, errWithContext := range aggregatedErr.LatestErrors {
+			if errWithContext.Timestamp.After(f.since) {
+				latestErrors = append(latestErrors, errWithContext)
+			}
+		}
+		if len(latestErrors) == 0 {
+			return aggregatedError{}, false
+		}
+		filtered.LatestErrors = latestErrors
+	}
+	if f.noInstances {
+		filtered.LatestErrors = []ErrorWithContext{}
+	}
+	return filtered, true
+}
+
+// apply sorts and limits the filtered aggregated errors
+func (f errorsFilter) apply(aggregatedErrors []aggregatedError) []aggregatedError {
+	if f.limit <= 0 || len(aggregatedErrors) <= f.limit {
+		return aggregatedErrors
+	}
+	sort.Slice(aggregatedErrors, func(i, j int) bool {
+		if aggregatedErrors[i].TotalCount != aggregatedErrors[j].TotalCount {
+			return aggregatedErrors[i].TotalCount > aggregatedErrors[j].TotalCount
+		}
+		return aggregatedErrors[i].AggregationKey < aggregatedErrors[j].AggregationKey
+	})
+	return aggregatedErrors[:f.limit]
+}
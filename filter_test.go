@@ -0,0 +1,116 @@
+package periskop
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+type classError struct{}
+
+func (e classError) Error() string { return "class error" }
+
+func newFilterCollector() *ErrorCollector {
+	c := NewErrorCollector()
+	c.Report(ErrorReport{Err: errors.New("testing"), ErrKey: "db.timeout", Severity: SeverityInfo})
+	c.Report(ErrorReport{Err: errors.New("testing"), ErrKey: "db.deadlock", Severity: SeverityWarning})
+	c.Report(ErrorReport{Err: errors.New("testing"), ErrKey: "db.deadlock", Severity: SeverityWarning})
+	c.Report(ErrorReport{Err: classError{}, ErrKey: "http.panic", Severity: SeverityCritical})
+	c.Report(ErrorReport{Err: classError{}, ErrKey: "http.panic", Severity: SeverityCritical})
+	c.Report(ErrorReport{Err: classError{}, ErrKey: "http.panic", Severity: SeverityCritical})
+	return &c
+}
+
+func TestFilter_getAggregatedErrors(t *testing.T) {
+	c := newFilterCollector()
+	old := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	c.aggregatedErrors["db.timeout"].LatestErrors[0].Timestamp = old
+	c.aggregatedErrors["http.panic"].LatestErrors[0].Timestamp = old
+
+	cases := []struct {
+		query    string
+		expected map[string]int
+	}{
+		{"", map[string]int{"db.timeout": 1, "db.deadlock": 2, "http.panic": 3}},
+		{"severity>=warning", map[string]int{"db.deadlock": 2, "http.panic": 3}},
+		{"severity=warning", map[string]int{"db.deadlock": 2}},
+		{"key=db.timeout", map[string]int{"db.timeout": 1}},
+		{"key_prefix=db.", map[string]int{"db.timeout": 1, "db.deadlock": 2}},
+		{"class=periskop.classError", map[string]int{"http.panic": 3}},
+		{"limit=2", map[string]int{"db.deadlock": 2, "http.panic": 3}},
+		{"since=2021-01-01T00:00:00Z", map[string]int{"db.deadlock": 2, "http.panic": 2}},
+		{"since=1609459200&instances=0", map[string]int{"db.deadlock": 0, "http.panic": 0}},
+	}
+	for _, tt := range cases {
+		t.Run(tt.query, func(t *testing.T) {
+			query, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			filter, err := parseErrorsFilter(query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			p := c.getAggregatedErrors(filter)
+			if len(p.AggregatedErrors) != len(tt.expected) {
+				t.Fatalf("expected %d aggregated errors, got %d", len(tt.expected), len(p.AggregatedErrors))
+			}
+			for _, aggregatedErr := range p.AggregatedErrors {
+				instances, ok := tt.expected[aggregatedErr.AggregationKey]
+				if !ok {
+					t.Errorf("unexpected aggregated error %s", aggregatedErr.AggregationKey)
+				}
+				if len(aggregatedErr.LatestErrors) != instances {
+					t.Errorf("expected %d instances of %s, got %d", instances, aggregatedErr.AggregationKey,
+						len(aggregatedErr.LatestErrors))
+				}
+			}
+		})
+	}
+
+	if len(c.aggregatedErrors["http.panic"].LatestErrors) != 3 {
+		t.Errorf("expected the collected errors not to change")
+	}
+}
+
+func TestFilter_NewHandler(t *testing.T) {
+	c := newFilterCollector()
+	rec := httptest.NewRecorder()
+	NewHandler(NewErrorExporter(c)).ServeHTTP(rec, httptest.NewRequest("GET", "/-/exceptions?severity>=error&instances=0", nil))
+
+	var p payload
+	if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+		t.Fatal(err)
+	}
+	if len(p.AggregatedErrors) != 1 || p.AggregatedErrors[0].AggregationKey != "http.panic" {
+		t.Fatalf("expected only the critical error, got %+v", p.AggregatedErrors)
+	}
+	if p.AggregatedErrors[0].TotalCount != 3 || len(p.AggregatedErrors[0].LatestErrors) != 0 {
+		t.Errorf("expected only the count, got %+v", p.AggregatedErrors[0])
+	}
+
+	for _, query := range []string{"severity=fatal", "since=yesterday", "limit=-1", "limit=0"} {
+		rec := httptest.NewRecorder()
+		NewHandler(NewErrorExporter(c)).ServeHTTP(rec, httptest.NewRequest("GET", "/-/exceptions?"+query, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("expected status 400 for %s, got %d", query, rec.Code)
+		}
+	}
+}
+
+func TestFilter_firstClass(t *testing.T) {
+	c := NewErrorCollector()
+	c.Report(ErrorReport{Err: errors.New("testing"), ErrKey: "testing"})
+	c.Report(ErrorReport{Err: classError{}, ErrKey: "testing"})
+
+	for class, expected := range map[string]int{"*errors.errorString": 1, "periskop.classError": 0} {
+		p := c.getAggregatedErrors(errorsFilter{class: class})
+		if len(p.AggregatedErrors) != expected {
+			t.Errorf("expected %d errors with class %s, got %d", expected, class, len(p.AggregatedErrors))
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package periskop
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// DefaultGatewayCooldown is the default time a pushgateway is considered unhealthy after a failed push
+const DefaultGatewayCooldown = 30 * time.Second
+
+// FailoverMode defines the order in which the pushgateways of a GatewayPool are tried
+type FailoverMode int
+
+const (
+	// FailoverPriority tries the pushgateways in the order they were given
+	FailoverPriority FailoverMode = iota
+	// FailoverRoundRobin starts every push with the pushgateway after the one that started the previous push
+	FailoverRoundRobin
+)
+
+// ErrNoGateways is returned when pushing to a GatewayPool without pushgateways
+var ErrNoGateways = errors.New("no pushgateways to push to")
+
+// GatewayHealth is the health of a pushgateway of a GatewayPool
+type GatewayHealth struct {
+	Addr string
+	// Healthy is false while the pushgateway is in cooldown after a failed push
+	Healthy bool
+	// ConsecutiveFailures is the number of failed pushes since the last successful one
+	ConsecutiveFailures int
+	LastSuccess         time.Time
+	LastFailure         time.Time
+	LastError           error
+}
+
+// GatewayPool is a group of pushgateways where errors are pushed, trying the next pushgateway when a
+// push fails. Pushgateways that failed are tried last until their cooldown ends.
+type GatewayPool struct {
+	mode FailoverMode
+	// Cooldown is the time a pushgateway is tried last after a failed push, defaults to DefaultGatewayCooldown
+	Cooldown time.Duration
+
+	mux      sync.Mutex
+	gateways []GatewayHealth
+	next     int
+}
+
+// NewGatewayPool creates a GatewayPool with the pushgateways specified by `addrs`
+func NewGatewayPool(mode FailoverMode, addrs ...string) *GatewayPool {
+	p := &GatewayPool{mode: mode, Cooldown: DefaultGatewayCooldown}
+	for _, addr := range addrs {
+		p.gateways = append(p.gateways, GatewayHealth{Addr: addr, Healthy: true})
+	}
+	return p
+}
+
+// Health gets the health of every pushgateway of the pool
+func (p *GatewayPool) Health() []GatewayHealth {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	now := time.Now()
+	health := make([]GatewayHealth, len(p.gateways))
+	for i, gateway := range p.gateways {
+		gateway.Healthy = p.healthy(gateway, now)
+		health[i] = gateway
+	}
+	return health
+}
+
+func (p *GatewayPool) healthy(gateway GatewayHealth, now time.Time) bool {
+	cooldown := p.Cooldown
+	if cooldown <= 0 {
+		cooldown = DefaultGatewayCooldown
+	}
+	return gateway.ConsecutiveFailures == 0 || now.Sub(gateway.LastFailure) >= cooldown
+}
+
+// order gets the indexes of the pushgateways in the order they have to be tried: the healthy ones
+// following the failover mode, and then the unhealthy ones
+func (p *GatewayPool) order() []int {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	start := 0
+	if p.mode == FailoverRoundRobin && len(p.gateways) > 0 {
+		start = p.next % len(p.gateways)
+		p.next++
+	}
+
+	now := time.Now()
+	var healthy, unhealthy []int
+	for i := range p.gateways {
+		idx := (start + i) % len(p.gateways)
+		if p.healthy(p.gateways[idx], now) {
+			healthy = append(healthy, idx)
+		} else {
+			unhealthy = append(unhealthy, idx)
+		}
+	}
+	return append(healthy, unhealthy...)
+}
+
+func (p *GatewayPool) record(idx int, err error) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	gateway := &p.gateways[idx]
+	if err == nil {
+		gateway.ConsecutiveFailures = 0
+		gateway.LastSuccess = time.Now()
+		gateway.LastError = nil
+		return
+	}
+	gateway.ConsecutiveFailures++
+	gateway.LastFailure = time.Now()
+	gateway.LastError = err
+}
+
+// send pushes `data` to the first pushgateway accepting it, and returns its address
+func (p *GatewayPool) send(ctx context.Context, data []byte, idempotencyKey string, opts PushOptions) (string, error) {
+	err := ErrNoGateways
+	for _, idx := range p.order() {
+		addr := p.gateways[idx].Addr
+		err = pushWithRetries(ctx, addr+"/errors", data, idempotencyKey, opts)
+		p.record(idx, err)
+		if err == nil {
+			return addr, nil
+		}
+		if ctx.Err() != nil || !failover(err) {
+			break
+		}
+	}
+	return "", err
+}
+
+// failover returns whether a push that failed with `err` has to be tried in the next pushgateway: network
+// errors and retryable responses (5xx and 429) depend on the pushgateway, while other responses (like 400
+// or 401) would be the same in every pushgateway
+func failover(err error) bool {
+	pushErr, ok := err.(*PushError)
+	return !ok || pushErr.retryable()
+}
+
+// PushToGateways pushes all collected errors to the pushgateways of `pool`, trying the next pushgateway
+// when a push fails, and returns the address of the pushgateway that accepted the push (empty if there
+// was nothing to push, like in a delta push without new errors). Pushes made
+// before the collected errors (like the ones left in a spool) are pushed to the pool too. See
+// PushToGatewayWithContext for the options.
+//
+// Delta pushes are tracked for every pushgateway, as the errors acknowledged by one of them were not pushed
+// to the others, and they are not spooled: a failed delta push is kept in memory and sent again to the same
+// pushgateway.
+func (e *ErrorExporter) PushToGateways(ctx context.Context, pool *GatewayPool, opts PushOptions) (string, error) {
+	opts.signer = e.signer
+	if opts.Delta {
+		return e.pushDeltaToGateways(ctx, pool, opts)
+	}
+	var accepted string
+	send := func(ctx context.Context, data []byte, idempotencyKey string) error {
+		addr, err := pool.send(ctx, data, idempotencyKey, opts)
+		if err == nil {
+			accepted = addr
+		}
+		return err
+	}
+	err := e.push(ctx, "", send, opts)
+	return accepted, err
+}
+
+// pushDeltaToGateways makes a delta push to the first pushgateway of `pool` accepting it, with the delta
+// state of that pushgateway
+func (e *ErrorExporter) pushDeltaToGateways(ctx context.Context, pool *GatewayPool, opts PushOptions) (string, error) {
+	opts.SpoolDir = ""
+	err := ErrNoGateways
+	for _, idx := range pool.order() {
+		addr := pool.gateways[idx].Addr
+		pushed := false
+		send := func(ctx context.Context, data []byte, idempotencyKey string) error {
+			err := pushWithRetries(ctx, addr+"/errors", data, idempotencyKey, opts)
+			pushed = pushed || err == nil
+			return err
+		}
+		err = e.pushDelta(ctx, addr, send, opts)
+		if err != nil || pushed {
+			pool.record(idx, err)
+		}
+		if err == nil {
+			if pushed {
+				return addr, nil
+			}
+			return "", nil
+		}
+		if ctx.Err() != nil || !failover(err) {
+			break
+		}
+	}
+	return "", err
+}
@@ -0,0 +1,142 @@
+package periskop
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestGateways_FailoverPriority(t *testing.T) {
+	primary, secondary := newFakeGateway(), newFakeGateway()
+	defer primary.Close()
+	defer secondary.Close()
+
+	c := NewErrorCollector()
+	c.ReportError(errors.New("testing"))
+	e := NewErrorExporter(&c)
+	pool := NewGatewayPool(FailoverPriority, primary.URL, secondary.URL)
+
+	for i := 0; i < 2; i++ {
+		addr, err := e.PushToGateways(context.Background(), pool, PushOptions{})
+		if err != nil || addr != primary.URL {
+			t.Errorf("expected push to the primary gateway, got %s, %v", addr, err)
+		}
+	}
+
+	primary.setDown(true)
+	addr, err := e.PushToGateways(context.Background(), pool, PushOptions{})
+	if err != nil || addr != secondary.URL {
+		t.Errorf("expected push to the secondary gateway, got %s, %v", addr, err)
+	}
+	health := pool.Health()
+	if health[0].Healthy || health[0].ConsecutiveFailures != 1 || !health[1].Healthy {
+		t.Errorf("expected primary gateway to be unhealthy, got %+v", health)
+	}
+
+	// the primary gateway is tried last while in cooldown
+	primary.setDown(false)
+	addr, _ = e.PushToGateways(context.Background(), pool, PushOptions{})
+	if addr != secondary.URL {
+		t.Errorf("expected push to the secondary gateway during cooldown, got %s", addr)
+	}
+	pool.Cooldown = time.Nanosecond
+	addr, _ = e.PushToGateways(context.Background(), pool, PushOptions{})
+	if addr != primary.URL {
+		t.Errorf("expected push to the primary gateway after cooldown, got %s", addr)
+	}
+	if pool.Health()[0].ConsecutiveFailures != 0 {
+		t.Errorf("expected primary gateway to be healthy")
+	}
+}
+
+func TestGateways_FailoverRoundRobin(t *testing.T) {
+	first, second := newFakeGateway(), newFakeGateway()
+	defer first.Close()
+	defer second.Close()
+
+	c := NewErrorCollector()
+	e := NewErrorExporter(&c)
+	pool := NewGatewayPool(FailoverRoundRobin, first.URL, second.URL)
+	for i := 0; i < 4; i++ {
+		if _, err := e.PushToGateways(context.Background(), pool, PushOptions{}); err != nil {
+			t.Fatalf("error pushing exceptions: %v", err)
+		}
+	}
+	firstPushes, secondPushes := len(first.pushed()), len(second.pushed())
+	if firstPushes != 2 || secondPushes != 2 {
+		t.Errorf("expected pushes to be balanced, got %d and %d", firstPushes, secondPushes)
+	}
+
+	first.setDown(true)
+	second.setDown(true)
+	_, err := e.PushToGateways(context.Background(), pool, PushOptions{})
+	if pushErr, ok := err.(*PushError); !ok || pushErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected a push error, got %v", err)
+	}
+
+	_, err = e.PushToGateways(context.Background(), NewGatewayPool(FailoverPriority), PushOptions{})
+	if err != ErrNoGateways {
+		t.Errorf("expected ErrNoGateways, got %v", err)
+	}
+}
+
+func TestGateways_clientError(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer primary.Close()
+	secondary := newFakeGateway()
+	defer secondary.Close()
+
+	c := NewErrorCollector()
+	c.ReportError(errors.New("testing"))
+	e := NewErrorExporter(&c)
+	pool := NewGatewayPool(FailoverPriority, primary.URL, secondary.URL)
+	_, err := e.PushToGateways(context.Background(), pool, PushOptions{})
+	if pushErr, ok := err.(*PushError); !ok || pushErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected the push error of the primary gateway, got %v", err)
+	}
+	if n := len(secondary.pushed()); n != 0 {
+		t.Errorf("expected no failover on client errors, got %d pushes", n)
+	}
+}
+
+func TestGateways_Delta(t *testing.T) {
+	primary, secondary := newFakeGateway(), newFakeGateway()
+	defer primary.Close()
+	defer secondary.Close()
+
+	c := NewErrorCollector()
+	e := NewErrorExporter(&c)
+	pool := NewGatewayPool(FailoverPriority, primary.URL, secondary.URL)
+	pool.Cooldown = time.Nanosecond
+	opts := PushOptions{Delta: true}
+
+	primary.setDown(true)
+	c.ReportError(errors.New("testing"))
+	if addr, err := e.PushToGateways(context.Background(), pool, opts); err != nil || addr != secondary.URL {
+		t.Fatalf("expected push to the secondary gateway, got %s, %v", addr, err)
+	}
+	primary.setDown(false)
+	c.ReportError(errors.New("testing"))
+	if addr, err := e.PushToGateways(context.Background(), pool, opts); err != nil || addr != primary.URL {
+		t.Fatalf("expected push to the primary gateway, got %s, %v", addr, err)
+	}
+
+	// every gateway gets all the errors not acknowledged by it
+	for _, tt := range []struct {
+		gateway  *fakeGateway
+		expected int
+	}{{primary, 2}, {secondary, 1}} {
+		totalCount := 0
+		for _, p := range tt.gateway.pushed() {
+			totalCount += p.AggregatedErrors[0].TotalCount
+		}
+		if totalCount != tt.expected {
+			t.Errorf("expected a total count of %d, got %d", tt.expected, totalCount)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package periskop
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/periskop-dev/periskop-go/errutils"
+)
+
+const (
+	// DefaultGoroutineDumpSize is the default size limit in bytes of goroutine dumps
+	DefaultGoroutineDumpSize int = 1 << 20
+	// MaxGoroutineGroups is the maximum number of groups of goroutines attached to a report
+	MaxGoroutineGroups int = 100
+)
+
+// GoroutineGroup is a group of goroutines with the same stack, like in the goroutine profile of pprof
+type GoroutineGroup struct {
+	Count int `json:"count"`
+	// State of the first goroutine of the group, without how long it has been waiting
+	State      string   `json:"state"`
+	Stacktrace []string `json:"stacktrace"`
+}
+
+// getGoroutineDump gets the stacks of all the goroutines, grouping the ones with the same stack.
+// The dump is limited to `size` bytes, dropping the goroutines that don't fit.
+func getGoroutineDump(size int) []GoroutineGroup {
+	if size <= 0 {
+		size = DefaultGoroutineDumpSize
+	}
+	buf := make([]byte, size)
+	n := runtime.Stack(buf, true)
+	buf = buf[:n]
+	if n == size {
+		// the dump was truncated, drop the last goroutine as it's incomplete
+		idx := bytes.LastIndex(buf, []byte("\n\n"))
+		if idx == -1 {
+			return nil
+		}
+		buf = buf[:idx]
+	}
+
+	goroutines, err := errutils.ParseGoroutineDump(string(buf))
+	if err != nil {
+		fmt.Printf("error parsing goroutine dump: %s\n", err)
+		return nil
+	}
+	return groupGoroutines(goroutines)
+}
+
+// groupGoroutines groups goroutines with the same stack. The first group is the one of the first goroutine
+// (the one that took the dump), followed by the rest of groups sorted by their number of goroutines.
+func groupGoroutines(goroutines []errutils.Goroutine) []GoroutineGroup {
+	var groups []GoroutineGroup
+	groupIndex := make(map[string]int)
+	for _, g := range goroutines {
+		stacktrace := formatGoroutineStack(g)
+		key := strings.Join(stacktrace, "\n")
+		if i, ok := groupIndex[key]; ok {
+			groups[i].Count++
+			continue
+		}
+		groupIndex[key] = len(groups)
+		groups = append(groups, GoroutineGroup{
+			Count:      1,
+			State:      goroutineState(g.State),
+			Stacktrace: stacktrace,
+		})
+	}
+
+	if len(groups) > 1 {
+		rest := groups[1:]
+		sort.SliceStable(rest, func(i, j int) bool { return rest[i].Count > rest[j].Count })
+	}
+	if len(groups) > MaxGoroutineGroups {
+		groups = groups[:MaxGoroutineGroups]
+	}
+	return groups
+}
+
+// formatGoroutineStack formats the stack of a goroutine like the runtime does, without the arguments
+// of the calls. Source lines are not included, as reading them for every goroutine would be too slow.
+func formatGoroutineStack(g errutils.Goroutine) []string {
+	stacktrace := make([]string, 0, 2*len(g.Frames)+2)
+	for _, frame := range g.Frames {
+		stacktrace = append(stacktrace, frameName(frame), fmt.Sprintf("\t%s:%d", frame.File, frame.LineNumber))
+	}
+	if g.CreatedBy != nil {
+		stacktrace = append(stacktrace, "created by "+frameName(*g.CreatedBy),
+			fmt.Sprintf("\t%s:%d", g.CreatedBy.File, g.CreatedBy.LineNumber))
+	}
+	return stacktrace
+}
+
+func frameName(frame errutils.StackFrame) string {
+	if frame.Package == "" {
+		return frame.Name
+	}
+	return frame.Package + "." + frame.Name
+}
+
+// goroutineState removes how long a goroutine has been waiting from its state, e.g. `chan receive, 2 minutes`
+func goroutineState(state string) string {
+	var parts []string
+	for _, part := range strings.Split(state, ", ") {
+		if !strings.HasSuffix(part, " minutes") && !strings.HasSuffix(part, " minute") {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,81 @@
+package periskop
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/periskop-dev/periskop-go/errutils"
+)
+
+func TestGoroutines_RecoverAndReport(t *testing.T) {
+	const blocked = 5
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(blocked)
+	for i := 0; i < blocked; i++ {
+		go func() {
+			wg.Done()
+			<-done
+		}()
+	}
+	wg.Wait()
+	defer close(done)
+
+	c := NewErrorCollector()
+	func() {
+		defer c.RecoverAndReport(RecoverOptions{GoroutineDump: true})
+		panic("boom")
+	}()
+
+	groups := getFirstAggregatedErr(c.aggregatedErrors).LatestErrors[0].Goroutines
+	if len(groups) < 2 {
+		t.Fatalf("expected a goroutine dump, got %v", groups)
+	}
+	if groups[0].Count != 1 || !strings.HasPrefix(groups[0].State, "running") {
+		t.Errorf("expected the panicking goroutine first, got %+v", groups[0])
+	}
+	found := false
+	for _, group := range groups {
+		if group.Count == blocked && strings.Contains(strings.Join(group.Stacktrace, "\n"),
+			"TestGoroutines_RecoverAndReport.func1") {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected the blocked goroutines grouped, got %+v", groups)
+	}
+}
+
+func TestGoroutines_getGoroutineDump_size(t *testing.T) {
+	groups := getGoroutineDump(100)
+	// the dump of the current goroutine doesn't fit in 100 bytes
+	if len(groups) != 0 {
+		t.Errorf("expected an empty dump, got %+v", groups)
+	}
+	if groups := getGoroutineDump(0); len(groups) == 0 {
+		t.Errorf("expected a dump with the default size")
+	}
+}
+
+func TestGoroutines_groupGoroutines(t *testing.T) {
+	frame := errutils.StackFrame{File: "/app/main.go", LineNumber: 10, Name: "main", Package: "main"}
+	other := errutils.StackFrame{File: "/app/worker.go", LineNumber: 3, Name: "work", Package: "main"}
+	goroutines := []errutils.Goroutine{
+		{ID: 1, State: "running", Frames: []errutils.StackFrame{frame}},
+		{ID: 2, State: "select", Frames: []errutils.StackFrame{other}},
+		{ID: 3, State: "chan receive, 2 minutes", Frames: []errutils.StackFrame{frame, other}},
+		{ID: 4, State: "chan receive", Frames: []errutils.StackFrame{frame, other}},
+	}
+	groups := groupGoroutines(goroutines)
+	if len(groups) != 3 {
+		t.Fatalf("expected three groups, got %+v", groups)
+	}
+	if groups[0].State != "running" || groups[1].Count != 2 || groups[1].State != "chan receive" {
+		t.Errorf("unexpected groups: %+v", groups)
+	}
+	expected := []string{"main.main", "\t/app/main.go:10", "main.work", "\t/app/worker.go:3"}
+	if strings.Join(groups[1].Stacktrace, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("unexpected stacktrace: %v", groups[1].Stacktrace)
+	}
+}
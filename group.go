@@ -0,0 +1,76 @@
+package periskop
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Group runs named tasks in goroutines, like golang.org/x/sync/errgroup, reporting the errors
+// returned by the tasks and their panics to an ErrorCollector. The name of the task is attached to
+// the reports in the `task` field.
+type Group struct {
+	collector *ErrorCollector
+	cancel    func()
+	wg        sync.WaitGroup
+	errOnce   sync.Once
+	err       error
+}
+
+// NewGroup creates a new Group reporting to the given collector
+func NewGroup(collector *ErrorCollector) *Group {
+	return &Group{collector: collector}
+}
+
+// NewGroupWithContext creates a new Group and a derived context that is canceled the first time
+// a task fails or when Wait returns
+func NewGroupWithContext(ctx context.Context, collector *ErrorCollector) (*Group, context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	return &Group{collector: collector, cancel: cancel}, ctx
+}
+
+// Go runs the task `f` named `task` in a new goroutine. A panic in the task is reported and
+// considered a failure of the task.
+func (g *Group) Go(task string, f func() error) {
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		defer func() {
+			if r := recover(); r != nil {
+				g.collector.reportPanic(r, RecoverOptions{Fields: map[string]interface{}{"task": task}})
+				g.fail(fmt.Errorf("task %s panicked: %v", task, r))
+			}
+		}()
+
+		if err := f(); err != nil {
+			g.collector.reportTaskError(err, task)
+			g.fail(err)
+		}
+	}()
+}
+
+// Wait blocks until all the tasks are done and returns the first error returned by them, if any
+func (g *Group) Wait() error {
+	g.wg.Wait()
+	if g.cancel != nil {
+		g.cancel()
+	}
+	return g.err
+}
+
+func (g *Group) fail(err error) {
+	g.errOnce.Do(func() {
+		g.err = err
+		if g.cancel != nil {
+			g.cancel()
+		}
+	})
+}
+
+// reportTaskError reports an error returned by a task of a Group
+func (c *ErrorCollector) reportTaskError(err error, task string) {
+	errWithContext := newErrorWithContextFromError(err, SeverityError, nil)
+	errWithContext.SetField("task", task)
+	errWithContext.Mechanism = MechanismGroup
+	c.addErrorWithContext(errWithContext, errWithContext.Severity, getErrorKey(err, ""))
+}
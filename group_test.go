@@ -0,0 +1,57 @@
+package periskop
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestGroup_Wait(t *testing.T) {
+	c := NewErrorCollector()
+	errTest := errors.New("testing")
+	g := NewGroup(&c)
+	g.Go("fails", func() error {
+		return errTest
+	})
+	g.Go("panics", func() error {
+		panic("boom")
+	})
+	g.Go("succeeds", func() error {
+		return nil
+	})
+
+	if err := g.Wait(); err == nil {
+		t.Errorf("expected an error")
+	}
+	if len(c.aggregatedErrors) != 2 {
+		t.Fatalf("expected two elements, got %d", len(c.aggregatedErrors))
+	}
+	tasks := make(map[interface{}]string)
+	for _, aggregatedErr := range c.aggregatedErrors {
+		errorWithContext := aggregatedErr.LatestErrors[0]
+		tasks[errorWithContext.Fields["task"]] = errorWithContext.Error.Class
+	}
+	if tasks["fails"] != "*errors.errorString" || tasks["panics"] != "panic(string)" {
+		t.Errorf("expected errors reported with their task name, got %v", tasks)
+	}
+}
+
+func TestGroup_firstError(t *testing.T) {
+	c := NewErrorCollector()
+	errTest := errors.New("testing")
+	g, ctx := NewGroupWithContext(context.Background(), &c)
+	g.Go("fails", func() error {
+		return errTest
+	})
+	g.Go("waits", func() error {
+		<-ctx.Done()
+		return nil
+	})
+
+	if err := g.Wait(); err != errTest {
+		t.Errorf("expected first error to be returned, got %v", err)
+	}
+	if ctx.Err() == nil {
+		t.Errorf("expected a canceled context")
+	}
+}
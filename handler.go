@@ -1,21 +1,260 @@
 package periskop
 
 import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"html/template"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 )
 
-// NewHandler receives a Periskop Error Exporter and returns
-// a handler with the exported errors in json format
+const (
+	jsonContentType   = "application/json"
+	ndjsonContentType = "application/x-ndjson"
+	htmlContentType   = "text/html; charset=utf-8"
+)
+
+// renderer renders the collected errors in a format
+type renderer struct {
+	mediaType   string
+	contentType string
+	render      func(e *ErrorExporter, filter errorsFilter) ([]byte, error)
+}
+
+// renderers are the formats supported by the handler, the first one being the default
+var renderers = []renderer{
+	{"application/json", jsonContentType, (*ErrorExporter).exportFiltered},
+	{"application/x-ndjson", ndjsonContentType, renderNDJSON},
+	{"application/openmetrics-text", OpenMetricsContentType, renderMetrics},
+	{"text/html", htmlContentType, renderHTML},
+}
+
+// NewHandler receives a Periskop Error Exporter and returns a handler with the exported errors. The
+// format is negotiated with the Accept header of the request between JSON (the default), NDJSON (one
+// aggregated error per line), OpenMetrics and HTML, with JSON used when the header matches none of them.
+// Formats excluded with `q=0` are not used, and 406 Not Acceptable is returned when all of them are.
+// The response is compressed with gzip when the client accepts it.
+//
+// The exported errors can be filtered with the query parameters `severity` (or `severity>=` for a
+// minimum severity), `since` (errors with instances newer than an RFC 3339 or unix timestamp), `key`,
+// `key_prefix`, `class` (of the first instance), `limit` (the errors with the highest counts) and
+// `instances=0` (only counts). Filters don't apply to OpenMetrics.
 func NewHandler(e ErrorExporter) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-		json, err := e.Export()
+		w.Header().Add("Vary", "Accept")
+		w.Header().Add("Vary", "Accept-Encoding")
+		r, ok := negotiate(req.Header.Get("Accept"))
+		if !ok {
+			writeError(w, http.StatusNotAcceptable, errNotAcceptable)
+			return
+		}
+		filter, err := parseErrorsFilter(req.URL.Query())
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		body, err := r.render(&e, filter)
 		if err != nil {
 			fmt.Printf("error exporting Periskop errors: %s\n", err)
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		if e.signer != nil {
+			// the uncompressed body is signed
+			w.Header().Set(SignatureHeader, e.signer.Sign(body, time.Now()))
+		}
+		w.Header().Set("Content-Type", r.contentType)
+		if acceptsGzip(req.Header.Get("Accept-Encoding")) {
+			w.Header().Set("Content-Encoding", "gzip")
+			gz := gzip.NewWriter(w)
+			defer gz.Close()
+			_, err = gz.Write(body)
+		} else {
+			_, err = w.Write(body)
 		}
-		_, err = w.Write([]byte(json))
 		if err != nil {
 			fmt.Printf("error writing Periskop errors: %s\n", err)
 		}
 	})
 }
+
+// errNotAcceptable is returned when the Accept header excludes all the formats
+var errNotAcceptable = errors.New("none of the supported formats is acceptable: application/json, " +
+	"application/x-ndjson, application/openmetrics-text or text/html")
+
+// negotiate gets the renderer of the format with the highest quality in the Accept header, where the
+// quality of a format is the one of the most specific media range matching it. Ties are resolved by the
+// order of the media ranges in the header. It returns false when every matching format is excluded
+// with `q=0` and the default format is not acceptable either.
+func negotiate(accept string) (renderer, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return renderers[0], true
+	}
+	parts := strings.Split(accept, ",")
+	best, bestQuality, bestPosition := -1, 0.0, 0
+	defaultExcluded := false
+	for i, r := range renderers {
+		quality, position, specificity := 0.0, 0, -1
+		for j, part := range parts {
+			mediaType, q := parseMediaRange(part)
+			if s := matchMediaRange(mediaType, r.mediaType); s > specificity {
+				quality, position, specificity = q, j, s
+			}
+		}
+		if specificity == -1 {
+			continue
+		}
+		if quality <= 0 {
+			defaultExcluded = defaultExcluded || i == 0
+			continue
+		}
+		if quality > bestQuality || (quality == bestQuality && position < bestPosition) {
+			best, bestQuality, bestPosition = i, quality, position
+		}
+	}
+	if best == -1 {
+		return renderers[0], !defaultExcluded
+	}
+	return renderers[best], true
+}
+
+// matchMediaRange returns how specific a media range matching a media type is (2 for `type/subtype`, 1
+// for `type/*` and 0 for `*/*`), or -1 if it doesn't match
+func matchMediaRange(mediaRange string, mediaType string) int {
+	switch {
+	case mediaRange == mediaType:
+		return 2
+	case strings.HasSuffix(mediaRange, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(mediaRange, "*")):
+		return 1
+	case mediaRange == "*/*":
+		return 0
+	}
+	return -1
+}
+
+// parseMediaRange parses a media range of an Accept header, like `text/html;q=0.9`
+func parseMediaRange(mediaRange string) (string, float64) {
+	params := strings.Split(mediaRange, ";")
+	mediaType := strings.ToLower(strings.TrimSpace(params[0]))
+	quality := 1.0
+	for _, param := range params[1:] {
+		kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+		if len(kv) == 2 && strings.TrimSpace(kv[0]) == "q" {
+			q, err := strconv.ParseFloat(strings.TrimSpace(kv[1]), 64)
+			if err == nil {
+				quality = q
+			}
+		}
+	}
+	return mediaType, quality
+}
+
+func acceptsGzip(acceptEncoding string) bool {
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		encoding, quality := parseMediaRange(part)
+		if encoding == "gzip" && quality > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// writeError writes an error response with a JSON body
+func writeError(w http.ResponseWriter, status int, err error) {
+	body, _ := json.Marshal(map[string]string{"error": err.Error()})
+	w.Header().Set("Content-Type", jsonContentType)
+	w.WriteHeader(status)
+	if _, err := w.Write(body); err != nil {
+		fmt.Printf("error writing Periskop error: %s\n", err)
+	}
+}
+
+// ndjsonLine is an aggregated error exported in NDJSON
+type ndjsonLine struct {
+	aggregatedError
+	TargetUUID string `json:"target_uuid"`
+}
+
+func renderNDJSON(e *ErrorExporter, filter errorsFilter) ([]byte, error) {
+	p := e.collector.getAggregatedErrors(filter)
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, aggregatedErr := range p.AggregatedErrors {
+		if err := enc.Encode(ndjsonLine{aggregatedErr, p.TargetUUID.String()}); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func renderMetrics(e *ErrorExporter, _ errorsFilter) ([]byte, error) {
+	var buf bytes.Buffer
+	err := e.writeMetrics(&buf, MaxMetricsKeys)
+	return buf.Bytes(), err
+}
+
+var htmlTemplate = template.Must(template.New("errors").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Periskop errors</title></head>
+<body>
+<h1>Periskop errors</h1>
+<p>Target {{.TargetUUID}}</p>
+<table>
+<tr><th>Aggregation key</th><th>Class</th><th>Severity</th><th>Count</th><th>Latest error</th><th>Latest at</th></tr>
+{{- range .Errors}}
+<tr><td>{{.AggregationKey}}</td><td>{{.Class}}</td><td>{{.Severity}}</td><td>{{.TotalCount}}</td><td>{{.Message}}</td><td>{{.LatestAt}}</td></tr>
+{{- end}}
+</table>
+</body>
+</html>
+`))
+
+// htmlError is an aggregated error shown in the HTML page
+type htmlError struct {
+	AggregationKey string
+	Class          string
+	Severity       Severity
+	TotalCount     int
+	Message        string
+	LatestAt       string
+}
+
+// renderHTML renders a page with the aggregated errors, the most frequent ones first
+func renderHTML(e *ErrorExporter, filter errorsFilter) ([]byte, error) {
+	p := e.collector.getAggregatedErrors(filter)
+	errs := make([]htmlError, 0, len(p.AggregatedErrors))
+	for _, aggregatedErr := range p.AggregatedErrors {
+		htmlErr := htmlError{
+			AggregationKey: aggregatedErr.AggregationKey,
+			Class:          aggregatedErr.class,
+			Severity:       aggregatedErr.Severity,
+			TotalCount:     aggregatedErr.TotalCount,
+		}
+		if n := len(aggregatedErr.LatestErrors); n > 0 {
+			latest := aggregatedErr.LatestErrors[n-1]
+			htmlErr.Message = latest.Error.Message
+			htmlErr.LatestAt = latest.Timestamp.Format(time.RFC3339)
+		}
+		errs = append(errs, htmlErr)
+	}
+	sort.SliceStable(errs, func(i, j int) bool {
+		if errs[i].TotalCount != errs[j].TotalCount {
+			return errs[i].TotalCount > errs[j].TotalCount
+		}
+		return errs[i].AggregationKey < errs[j].AggregationKey
+	})
+
+	var buf bytes.Buffer
+	err := htmlTemplate.Execute(&buf, struct {
+		TargetUUID string
+		Errors     []htmlError
+	}{p.TargetUUID.String(), errs})
+	return buf.Bytes(), err
+}
@@ -0,0 +1,175 @@
+package periskop
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func serveErrors(c *ErrorCollector, accept, acceptEncoding string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", "/-/exceptions", nil)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	if acceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	rec := httptest.NewRecorder()
+	NewHandler(NewErrorExporter(c)).ServeHTTP(rec, req)
+	return rec
+}
+
+func TestHandler_negotiate(t *testing.T) {
+	cases := []struct {
+		accept      string
+		contentType string
+	}{
+		{"", jsonContentType},
+		{"*/*", jsonContentType},
+		{"application/json", jsonContentType},
+		{"application/x-ndjson", ndjsonContentType},
+		{"application/openmetrics-text; version=1.0.0", OpenMetricsContentType},
+		{"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", htmlContentType},
+		{"text/*", htmlContentType},
+		{"application/json;q=0.5, application/x-ndjson", ndjsonContentType},
+		{"image/png", jsonContentType},
+		{"application/json;q=0, text/html", htmlContentType},
+		{"text/html;q=0, */*", jsonContentType},
+	}
+	c := NewErrorCollector()
+	c.ReportError(errors.New("<b>testing</b>"))
+	for _, tt := range cases {
+		t.Run(tt.accept, func(t *testing.T) {
+			rec := serveErrors(&c, tt.accept, "")
+			if rec.Code != http.StatusOK {
+				t.Errorf("incorrect status, got %d", rec.Code)
+			}
+			if contentType := rec.Header().Get("Content-Type"); contentType != tt.contentType {
+				t.Errorf("incorrect content type, got %s", contentType)
+			}
+		})
+	}
+}
+
+func TestHandler_notAcceptable(t *testing.T) {
+	c := NewErrorCollector()
+	for _, accept := range []string{"application/json;q=0", "*/*;q=0", "image/png, application/*;q=0"} {
+		t.Run(accept, func(t *testing.T) {
+			rec := serveErrors(&c, accept, "")
+			if rec.Code != http.StatusNotAcceptable {
+				t.Errorf("expected status 406, got %d", rec.Code)
+			}
+		})
+	}
+}
+
+func TestHandler_formats(t *testing.T) {
+	c := NewErrorCollector()
+	c.Report(ErrorReport{Err: errors.New("<b>testing</b>"), ErrKey: "first"})
+	c.Report(ErrorReport{Err: errors.New("testing"), ErrKey: "second"})
+
+	rec := serveErrors(&c, "application/x-ndjson", "")
+	scanner := bufio.NewScanner(rec.Body)
+	lines := 0
+	for scanner.Scan() {
+		var line map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Errorf("invalid NDJSON line: %v", err)
+		}
+		if line["target_uuid"] != c.uuid.String() || line["aggregation_key"] == nil {
+			t.Errorf("unexpected NDJSON line: %s", scanner.Text())
+		}
+		lines++
+	}
+	if lines != 2 {
+		t.Errorf("expected one line for every aggregated error, got %d", lines)
+	}
+
+	rec = serveErrors(&c, "text/html", "")
+	if body := rec.Body.String(); !strings.Contains(body, "&lt;b&gt;testing&lt;/b&gt;") || strings.Contains(body, "<b>") {
+		t.Errorf("expected escaped error messages in HTML:\n%s", body)
+	}
+
+	rec = serveErrors(&c, "application/openmetrics-text", "")
+	if !strings.Contains(rec.Body.String(), `periskop_errors_total{aggregation_key="first"`) {
+		t.Errorf("expected metrics:\n%s", rec.Body.String())
+	}
+}
+
+func TestHandler_gzip(t *testing.T) {
+	c := NewErrorCollector()
+	c.ReportError(errors.New("testing"))
+	rec := serveErrors(&c, "", "deflate, gzip;q=0.5")
+	if rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("expected gzip content encoding")
+	}
+	gz, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := ioutil.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var p payload
+	if err := json.Unmarshal(body, &p); err != nil || len(p.AggregatedErrors) != 1 {
+		t.Errorf("expected one aggregated error, got %s", body)
+	}
+
+	if rec := serveErrors(&c, "", "gzip;q=0"); rec.Header().Get("Content-Encoding") != "" {
+		t.Errorf("expected no content encoding")
+	}
+}
+
+func TestHandler_unexportableFields(t *testing.T) {
+	c := NewErrorCollector()
+	c.AddBeforeReportHook(func(errWithContext *ErrorWithContext) bool {
+		// channels can't be exported in JSON
+		errWithContext.SetField("channel", make(chan int))
+		errWithContext.SetField("ratio", math.NaN())
+		errWithContext.SetField("attempts", 3)
+		return true
+	})
+	c.ReportError(errors.New("testing"))
+
+	rec := serveErrors(&c, "application/json", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	var p payload
+	if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil || len(p.AggregatedErrors) != 1 {
+		t.Fatalf("expected one aggregated error, got %s", rec.Body.String())
+	}
+	fields := p.AggregatedErrors[0].LatestErrors[0].Fields
+	if fields["ratio"] != "NaN" || fields["attempts"] != float64(3) {
+		t.Errorf("unexpected fields: %v", fields)
+	}
+	if _, ok := fields["channel"].(string); !ok {
+		t.Errorf("expected the channel to be exported as a string, got %v", fields["channel"])
+	}
+}
+
+func TestHandler_exportError(t *testing.T) {
+	defer func(original []renderer) { renderers = original }(renderers)
+	renderers = append([]renderer{{"application/x-failing", jsonContentType,
+		func(e *ErrorExporter, filter errorsFilter) ([]byte, error) {
+			return nil, errors.New("rendering failed")
+		}}}, renderers...)
+
+	c := NewErrorCollector()
+	rec := serveErrors(&c, "application/x-failing", "")
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("expected status 500, got %d", rec.Code)
+	}
+	var body map[string]string
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body["error"] != "rendering failed" {
+		t.Errorf("expected a JSON error, got %s", rec.Body.String())
+	}
+}
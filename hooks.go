@@ -0,0 +1,42 @@
+package periskop
+
+import (
+	"context"
+	"errors"
+	"io"
+	"syscall"
+)
+
+// BeforeReportHook is called with every error before it's added to the collector. Hooks can modify
+// the error (message, severity, fields or aggregation key) and drop it by returning false.
+type BeforeReportHook func(errWithContext *ErrorWithContext) (keep bool)
+
+// IgnoreContextCanceled drops errors caused by a canceled context
+func IgnoreContextCanceled(errWithContext *ErrorWithContext) bool {
+	return !errors.Is(errWithContext.Err(), context.Canceled)
+}
+
+// IgnoreEOF drops errors caused by io.EOF
+func IgnoreEOF(errWithContext *ErrorWithContext) bool {
+	return !errors.Is(errWithContext.Err(), io.EOF)
+}
+
+// DowngradeClientDisconnect reports errors caused by a client closing the connection
+// (broken pipe or connection reset) with severity Info
+func DowngradeClientDisconnect(errWithContext *ErrorWithContext) bool {
+	err := errWithContext.Err()
+	if errors.Is(err, syscall.EPIPE) || errors.Is(err, syscall.ECONNRESET) {
+		errWithContext.Severity = SeverityInfo
+	}
+	return true
+}
+
+// runHooks runs all the hooks in order, stopping at the first one that drops the error
+func runHooks(hooks []BeforeReportHook, errWithContext *ErrorWithContext) bool {
+	for _, hook := range hooks {
+		if !hook(errWithContext) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,63 @@
+package periskop
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"syscall"
+	"testing"
+)
+
+func TestHooks_dropError(t *testing.T) {
+	c := NewErrorCollector()
+	c.AddBeforeReportHook(IgnoreContextCanceled, IgnoreEOF)
+	c.ReportError(context.Canceled)
+	c.ReportError(fmt.Errorf("reading body: %w", io.EOF))
+
+	if len(c.aggregatedErrors) != 0 {
+		t.Errorf("expected no errors, got %d", len(c.aggregatedErrors))
+	}
+
+	c.ReportError(errors.New("testing"))
+	if len(c.aggregatedErrors) != 1 {
+		t.Errorf("expected one element")
+	}
+}
+
+func TestHooks_DowngradeClientDisconnect(t *testing.T) {
+	c := NewErrorCollector()
+	c.AddBeforeReportHook(DowngradeClientDisconnect)
+	c.ReportError(fmt.Errorf("write response: %w", syscall.EPIPE))
+
+	aggregatedErr := getFirstAggregatedErr(c.aggregatedErrors)
+	if aggregatedErr.Severity != SeverityInfo {
+		t.Errorf("incorrect severity, got %s", aggregatedErr.Severity)
+	}
+	if aggregatedErr.LatestErrors[0].Severity != SeverityInfo {
+		t.Errorf("incorrect severity, got %s", aggregatedErr.LatestErrors[0].Severity)
+	}
+}
+
+func TestHooks_modifyError(t *testing.T) {
+	c := NewErrorCollector()
+	c.AddBeforeReportHook(func(errWithContext *ErrorWithContext) bool {
+		errWithContext.Error.Message = "redacted"
+		errWithContext.ErrKey = "custom-key"
+		errWithContext.SetField("tenant", "acme")
+		return true
+	})
+	c.ReportError(errors.New("secret"))
+
+	aggregatedErr, ok := c.aggregatedErrors["custom-key"]
+	if !ok {
+		t.Fatalf("expected error aggregated with key overridden by hook")
+	}
+	errorWithContext := aggregatedErr.LatestErrors[0]
+	if errorWithContext.Error.Message != "redacted" {
+		t.Errorf("expected message rewritten by hook, got %s", errorWithContext.Error.Message)
+	}
+	if errorWithContext.Fields["tenant"] != "acme" {
+		t.Errorf("expected field attached by hook, got %v", errorWithContext.Fields)
+	}
+}
@@ -0,0 +1,102 @@
+package periskop
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+const (
+	// MaxMetricsKeys is the maximum number of aggregation keys exposed as metrics. The errors of the
+	// aggregation keys created later are exposed with the `other` aggregation key and class.
+	MaxMetricsKeys int = 1000
+	// OpenMetricsContentType is the content type of the metrics exposed by NewMetricsHandler
+	OpenMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+	otherMetricsLabel      = "other"
+)
+
+// NewMetricsHandler receives a Periskop Error Exporter and returns a handler with the number of
+// collected errors in the OpenMetrics text format, to be scraped by Prometheus
+func NewMetricsHandler(e ErrorExporter) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", OpenMetricsContentType)
+		err := e.writeMetrics(w, MaxMetricsKeys)
+		if err != nil {
+			fmt.Printf("error writing Periskop metrics: %s\n", err)
+		}
+	})
+}
+
+type errorsMetric struct {
+	aggregationKey string
+	class          string
+	severity       Severity
+	count          int
+}
+
+// writeMetrics writes the metrics of the collected errors, exposing at most `maxKeys` aggregation keys.
+// As counters can't decrease, the exposed keys are the first ones created, which never change.
+func (e *ErrorExporter) writeMetrics(w io.Writer, maxKeys int) error {
+	aggregatedErrors := e.collector.getAggregatedErrors(errorsFilter{}).AggregatedErrors
+	sort.Slice(aggregatedErrors, func(i, j int) bool {
+		if !aggregatedErrors[i].CreatedAt.Equal(aggregatedErrors[j].CreatedAt) {
+			return aggregatedErrors[i].CreatedAt.Before(aggregatedErrors[j].CreatedAt)
+		}
+		return aggregatedErrors[i].AggregationKey < aggregatedErrors[j].AggregationKey
+	})
+
+	var metrics []errorsMetric
+	others := make(map[Severity]int)
+	for i, aggregatedErr := range aggregatedErrors {
+		if i >= maxKeys {
+			others[aggregatedErr.Severity] += aggregatedErr.TotalCount
+			continue
+		}
+		// the class of the first error, so the series of an aggregation key doesn't change between scrapes
+		metrics = append(metrics, errorsMetric{aggregatedErr.AggregationKey, aggregatedErr.class, aggregatedErr.Severity,
+			aggregatedErr.TotalCount})
+	}
+	var otherSeverities []string
+	for severity := range others {
+		otherSeverities = append(otherSeverities, string(severity))
+	}
+	sort.Strings(otherSeverities)
+	for _, severity := range otherSeverities {
+		metrics = append(metrics, errorsMetric{otherMetricsLabel, otherMetricsLabel, Severity(severity),
+			others[Severity(severity)]})
+	}
+
+	var b strings.Builder
+	b.WriteString("# TYPE periskop_errors counter\n")
+	b.WriteString("# HELP periskop_errors Number of collected errors.\n")
+	for _, m := range metrics {
+		fmt.Fprintf(&b, "periskop_errors_total{aggregation_key=\"%s\",class=\"%s\",severity=\"%s\"} %d\n",
+			escapeLabelValue(m.aggregationKey), escapeLabelValue(m.class), escapeLabelValue(string(m.severity)),
+			m.count)
+	}
+	b.WriteString("# TYPE periskop_dropped_reports counter\n")
+	b.WriteString("# HELP periskop_dropped_reports Number of reports dropped by hooks.\n")
+	fmt.Fprintf(&b, "periskop_dropped_reports_total %d\n", e.collector.getDroppedReports())
+	b.WriteString("# TYPE periskop_aggregation_keys gauge\n")
+	b.WriteString("# HELP periskop_aggregation_keys Number of aggregation keys of the collected errors.\n")
+	fmt.Fprintf(&b, "periskop_aggregation_keys %d\n", len(aggregatedErrors))
+	b.WriteString("# TYPE periskop_folded_aggregation_keys gauge\n")
+	b.WriteString("# HELP periskop_folded_aggregation_keys Number of aggregation keys exposed as other.\n")
+	folded := len(aggregatedErrors) - maxKeys
+	if folded < 0 {
+		folded = 0
+	}
+	fmt.Fprintf(&b, "periskop_folded_aggregation_keys %d\n", folded)
+	b.WriteString("# EOF\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(value string) string {
+	return labelValueReplacer.Replace(value)
+}
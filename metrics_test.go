@@ -0,0 +1,88 @@
+package periskop
+
+import (
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetrics_NewMetricsHandler(t *testing.T) {
+	c := NewErrorCollector()
+	c.AddBeforeReportHook(IgnoreEOF)
+	c.Report(ErrorReport{Err: errors.New("testing"), ErrKey: "testing"})
+	c.Report(ErrorReport{Err: errors.New("testing"), ErrKey: "testing"})
+	c.Report(ErrorReport{Err: errors.New("quoted"), ErrKey: `say "hi"`, Severity: SeverityWarning})
+	c.ReportError(io.EOF)
+
+	rec := httptest.NewRecorder()
+	NewMetricsHandler(NewErrorExporter(&c)).ServeHTTP(rec, httptest.NewRequest("GET", "/-/metrics", nil))
+	if rec.Header().Get("Content-Type") != OpenMetricsContentType {
+		t.Errorf("incorrect content type, got %s", rec.Header().Get("Content-Type"))
+	}
+	body := rec.Body.String()
+	expected := []string{
+		`periskop_errors_total{aggregation_key="testing",class="*errors.errorString",severity="error"} 2`,
+		`periskop_errors_total{aggregation_key="say \"hi\"",class="*errors.errorString",severity="warning"} 1`,
+		"periskop_dropped_reports_total 1",
+		"periskop_aggregation_keys 2",
+		"periskop_folded_aggregation_keys 0",
+	}
+	for _, line := range expected {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("expected %s in metrics:\n%s", line, body)
+		}
+	}
+	if !strings.HasSuffix(body, "# EOF\n") {
+		t.Errorf("expected metrics to end with # EOF")
+	}
+}
+
+func TestMetrics_maxKeys(t *testing.T) {
+	c := NewErrorCollector()
+	for i, key := range []string{"first", "second", "third", "fourth"} {
+		c.Report(ErrorReport{Err: errors.New("testing"), ErrKey: key})
+		c.aggregatedErrors[key].CreatedAt = time.Date(2020, 1, i+1, 0, 0, 0, 0, time.UTC)
+	}
+	c.Report(ErrorReport{Err: errors.New("testing"), ErrKey: "fourth"})
+
+	e := NewErrorExporter(&c)
+	var b strings.Builder
+	if err := e.writeMetrics(&b, 2); err != nil {
+		t.Fatal(err)
+	}
+	body := b.String()
+	expected := []string{
+		`periskop_errors_total{aggregation_key="first",class="*errors.errorString",severity="error"} 1`,
+		`periskop_errors_total{aggregation_key="second",class="*errors.errorString",severity="error"} 1`,
+		`periskop_errors_total{aggregation_key="other",class="other",severity="error"} 3`,
+		"periskop_aggregation_keys 4",
+		"periskop_folded_aggregation_keys 2",
+	}
+	for _, line := range expected {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("expected %s in metrics:\n%s", line, body)
+		}
+	}
+	if strings.Contains(body, `aggregation_key="third"`) {
+		t.Errorf("expected the newest keys to be folded")
+	}
+}
+
+func TestMetrics_stableClass(t *testing.T) {
+	c := NewErrorCollector()
+	c.Report(ErrorReport{Err: errors.New("testing"), ErrKey: "testing"})
+	c.Report(ErrorReport{Err: classError{}, ErrKey: "testing"})
+
+	e := NewErrorExporter(&c)
+	var b strings.Builder
+	if err := e.writeMetrics(&b, MaxMetricsKeys); err != nil {
+		t.Fatal(err)
+	}
+	expected := `periskop_errors_total{aggregation_key="testing",class="*errors.errorString",severity="error"} 2`
+	if !strings.Contains(b.String(), expected+"\n") {
+		t.Errorf("expected the class of the first error in metrics:\n%s", b.String())
+	}
+}
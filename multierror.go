@@ -0,0 +1,49 @@
+package periskop
+
+import (
+	"errors"
+)
+
+// MultiErrorMode defines how errors wrapping several independent errors are reported.
+// Errors created with errors.Join and any error implementing `Unwrap() []error`, `WrappedErrors() []error`
+// (hashicorp/go-multierror) or `Errors() []error` (uber-go/multierr) are considered multi-errors.
+type MultiErrorMode int
+
+const (
+	// MultiErrorSingle reports a multi-error as a single error
+	MultiErrorSingle MultiErrorMode = iota
+	// MultiErrorFanOut reports every wrapped error on its own, sharing a correlation ID
+	MultiErrorFanOut
+	// MultiErrorCauses reports a multi-error as a single error with the wrapped errors as its causes
+	MultiErrorCauses
+)
+
+// unwrapMultiError gets the errors wrapped by the first multi-error in the chain of `err`,
+// or nil if there's none
+func unwrapMultiError(err error) []error {
+	for ; err != nil; err = errors.Unwrap(err) {
+		var errs []error
+		switch e := err.(type) {
+		case interface{ Unwrap() []error }:
+			errs = e.Unwrap()
+		case interface{ WrappedErrors() []error }:
+			errs = e.WrappedErrors()
+		case interface{ Errors() []error }:
+			errs = e.Errors()
+		default:
+			continue
+		}
+		return removeNilErrors(errs)
+	}
+	return nil
+}
+
+func removeNilErrors(errs []error) []error {
+	var res []error
+	for _, err := range errs {
+		if err != nil {
+			res = append(res, err)
+		}
+	}
+	return res
+}
@@ -0,0 +1,80 @@
+package periskop
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// joinedError mimics the errors created with errors.Join
+type joinedError struct {
+	errs []error
+}
+
+func (e *joinedError) Error() string {
+	msgs := make([]string, 0, len(e.errs))
+	for _, err := range e.errs {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+func (e *joinedError) Unwrap() []error {
+	return e.errs
+}
+
+func newJoinedError() error {
+	return fmt.Errorf("closing resources: %w", &joinedError{[]error{
+		errors.New("closing db"),
+		nil,
+		&timeoutError{},
+	}})
+}
+
+func TestMultiError_single(t *testing.T) {
+	c := NewErrorCollector()
+	c.ReportError(newJoinedError())
+
+	if len(c.aggregatedErrors) != 1 {
+		t.Errorf("expected one element")
+	}
+	errorWithContext := getFirstAggregatedErr(c.aggregatedErrors).LatestErrors[0]
+	if len(errorWithContext.Error.Causes) != 0 {
+		t.Errorf("expected no causes, got %d", len(errorWithContext.Error.Causes))
+	}
+}
+
+func TestMultiError_fanOut(t *testing.T) {
+	c := NewErrorCollector()
+	c.SetMultiErrorMode(MultiErrorFanOut)
+	c.ReportError(newJoinedError())
+
+	if len(c.aggregatedErrors) != 2 {
+		t.Fatalf("expected two elements, got %d", len(c.aggregatedErrors))
+	}
+	var correlationIDs []string
+	for _, aggregatedErr := range c.aggregatedErrors {
+		correlationIDs = append(correlationIDs, aggregatedErr.LatestErrors[0].CorrelationID)
+	}
+	if correlationIDs[0] == "" || correlationIDs[0] != correlationIDs[1] {
+		t.Errorf("expected a shared correlation ID, got %v", correlationIDs)
+	}
+}
+
+func TestMultiError_causes(t *testing.T) {
+	c := NewErrorCollector()
+	c.SetMultiErrorMode(MultiErrorCauses)
+	c.ReportError(newJoinedError())
+
+	if len(c.aggregatedErrors) != 1 {
+		t.Errorf("expected one element")
+	}
+	causes := getFirstAggregatedErr(c.aggregatedErrors).LatestErrors[0].Error.Causes
+	if len(causes) != 2 {
+		t.Fatalf("expected two causes, got %d", len(causes))
+	}
+	if causes[0].Message != "closing db" || causes[1].Class != "*periskop.timeoutError" {
+		t.Errorf("unexpected causes: %+v", causes)
+	}
+}
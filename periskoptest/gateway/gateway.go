@@ -0,0 +1,303 @@
+// Package gateway implements a pushgateway receiving the errors pushed by periskop collectors, to test
+// pushes end to end or to embed a gateway in an existing process
+package gateway
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/periskop-dev/periskop-go"
+)
+
+const (
+	// MaxIdempotencyKeys is the number of idempotency keys kept per target. Delta pushes older than the
+	// latest MaxIdempotencyKeys ones are not recognized if they are pushed again.
+	MaxIdempotencyKeys = 1000
+	// DefaultTargetTTL is the default time the errors of a target are kept after its last push
+	DefaultTargetTTL = 24 * time.Hour
+)
+
+// Payload is the payload pushed by a collector, and the one exposed by the scrape endpoint
+type Payload struct {
+	AggregatedErrors []AggregatedError `json:"aggregated_errors"`
+	TargetUUID       uuid.UUID         `json:"target_uuid"`
+	Target           *periskop.Target  `json:"target,omitempty"`
+	Delta            bool              `json:"delta,omitempty"`
+	Sequence         uint64            `json:"sequence,omitempty"`
+	IdempotencyKey   string            `json:"idempotency_key,omitempty"`
+}
+
+// AggregatedError is a group of errors with the same aggregation key
+type AggregatedError struct {
+	AggregationKey string                      `json:"aggregation_key"`
+	TotalCount     int                         `json:"total_count"`
+	Severity       periskop.Severity           `json:"severity"`
+	LatestErrors   []periskop.ErrorWithContext `json:"latest_errors"`
+	CreatedAt      time.Time                   `json:"created_at"`
+}
+
+// target holds the errors pushed by a collector
+type target struct {
+	aggregatedErrors map[string]*AggregatedError
+	metadata         *periskop.Target
+	// idempotencyKeys are the keys of the latest delta pushes already counted, in the order they were pushed
+	idempotencyKeys []string
+	seenKeys        map[string]bool
+	lastPush        time.Time
+}
+
+func newTarget() *target {
+	return &target{aggregatedErrors: make(map[string]*AggregatedError), seenKeys: make(map[string]bool)}
+}
+
+// addIdempotencyKey records the key of a delta push, returning false if it was already pushed
+func (t *target) addIdempotencyKey(key string) bool {
+	if t.seenKeys[key] {
+		return false
+	}
+	t.seenKeys[key] = true
+	t.idempotencyKeys = append(t.idempotencyKeys, key)
+	if len(t.idempotencyKeys) > MaxIdempotencyKeys {
+		delete(t.seenKeys, t.idempotencyKeys[0])
+		t.idempotencyKeys = t.idempotencyKeys[1:]
+	}
+	return true
+}
+
+// Gateway receives pushes in `POST /errors` and exposes the errors of all the targets merged in
+// `GET /-/exceptions`
+type Gateway struct {
+	mux      sync.Mutex
+	uuid     uuid.UUID
+	targets  map[uuid.UUID]*target
+	pushes   int
+	handler  *http.ServeMux
+	verifier *periskop.Verifier
+	down     bool
+	ttl      time.Duration
+}
+
+// New creates a new Gateway
+func New() *Gateway {
+	g := &Gateway{
+		uuid:    uuid.New(),
+		targets: make(map[uuid.UUID]*target),
+		handler: http.NewServeMux(),
+		ttl:     DefaultTargetTTL,
+	}
+	g.handler.HandleFunc("/errors", g.handlePush)
+	g.handler.HandleFunc("/-/exceptions", g.handleScrape)
+	return g
+}
+
+// NewServer starts a test HTTP server with a new Gateway, to be closed by the caller
+func NewServer() (*httptest.Server, *Gateway) {
+	g := New()
+	return httptest.NewServer(g), g
+}
+
+// SetVerifier makes the gateway reject pushes without a valid signature
+func (g *Gateway) SetVerifier(verifier *periskop.Verifier) {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+	g.verifier = verifier
+}
+
+// SetDown simulates an outage of the gateway, which rejects pushes with 503 Service Unavailable while down
+func (g *Gateway) SetDown(down bool) {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+	g.down = down
+}
+
+// SetTargetTTL sets the time the errors of a target are kept after its last push (DefaultTargetTTL by default)
+func (g *Gateway) SetTargetTTL(ttl time.Duration) {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+	g.ttl = ttl
+}
+
+// ServeHTTP implements http.Handler
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g.handler.ServeHTTP(w, r)
+}
+
+func (g *Gateway) handlePush(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	g.mux.Lock()
+	down := g.down
+	g.mux.Unlock()
+	if down {
+		http.Error(w, "gateway is down", http.StatusServiceUnavailable)
+		return
+	}
+
+	var body io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid gzip body: %s", err), http.StatusBadRequest)
+			return
+		}
+		defer gz.Close()
+		body = gz
+	}
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("error reading payload: %s", err), http.StatusBadRequest)
+		return
+	}
+	g.mux.Lock()
+	verifier := g.verifier
+	g.mux.Unlock()
+	if verifier != nil {
+		if err := verifier.Verify(r.Header.Get(periskop.SignatureHeader), data); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+	}
+
+	var p Payload
+	if err := json.Unmarshal(data, &p); err != nil {
+		http.Error(w, fmt.Sprintf("invalid payload: %s", err), http.StatusBadRequest)
+		return
+	}
+	g.Push(p)
+}
+
+func (g *Gateway) handleScrape(w http.ResponseWriter, r *http.Request) {
+	data, err := json.Marshal(g.Payload())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(data)
+	if err != nil {
+		fmt.Printf("error writing Periskop errors: %s\n", err)
+	}
+}
+
+// Push adds a payload pushed by a collector. A full payload replaces the errors previously pushed by
+// the same target, while the errors of a delta payload are added to them. Delta payloads with an
+// idempotency key that was already pushed are ignored. Targets that didn't push during the target TTL
+// are removed.
+func (g *Gateway) Push(p Payload) {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+	g.pushes++
+	g.expire()
+
+	t, ok := g.targets[p.TargetUUID]
+	if !ok || !p.Delta {
+		t = newTarget()
+		if ok {
+			t.idempotencyKeys = g.targets[p.TargetUUID].idempotencyKeys
+			t.seenKeys = g.targets[p.TargetUUID].seenKeys
+		}
+		g.targets[p.TargetUUID] = t
+	}
+	t.lastPush = time.Now()
+	if p.Target != nil {
+		t.metadata = p.Target
+	}
+	if p.Delta && p.IdempotencyKey != "" && !t.addIdempotencyKey(p.IdempotencyKey) {
+		return
+	}
+	for _, aggregatedErr := range p.AggregatedErrors {
+		merge(t.aggregatedErrors, aggregatedErr)
+	}
+}
+
+// Payload gets the errors of all the targets, merging the ones with the same aggregation key
+func (g *Gateway) Payload() Payload {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+	g.expire()
+	merged := make(map[string]*AggregatedError)
+	for _, t := range g.targets {
+		for _, aggregatedErr := range t.aggregatedErrors {
+			merge(merged, *aggregatedErr)
+		}
+	}
+
+	aggregatedErrors := make([]AggregatedError, 0, len(merged))
+	for _, aggregatedErr := range merged {
+		aggregatedErrors = append(aggregatedErrors, *aggregatedErr)
+	}
+	sort.Slice(aggregatedErrors, func(i, j int) bool {
+		return aggregatedErrors[i].AggregationKey < aggregatedErrors[j].AggregationKey
+	})
+	return Payload{AggregatedErrors: aggregatedErrors, TargetUUID: g.uuid}
+}
+
+// Targets gets the metadata of the targets that pushed errors, by target UUID
+func (g *Gateway) Targets() map[uuid.UUID]periskop.Target {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+	g.expire()
+	targets := make(map[uuid.UUID]periskop.Target, len(g.targets))
+	for id, t := range g.targets {
+		var metadata periskop.Target
+		if t.metadata != nil {
+			metadata = *t.metadata
+		}
+		targets[id] = metadata
+	}
+	return targets
+}
+
+// Pushes gets the number of pushes received, including the ignored ones
+func (g *Gateway) Pushes() int {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+	return g.pushes
+}
+
+// expire removes the targets that didn't push during the target TTL
+func (g *Gateway) expire() {
+	for id, t := range g.targets {
+		if time.Since(t.lastPush) > g.ttl {
+			delete(g.targets, id)
+		}
+	}
+}
+
+// merge adds an aggregated error to `aggregatedErrors` with the same rules as the collector: counts
+// are added, the first severity is kept and only the latest periskop.MaxErrors errors are kept
+func merge(aggregatedErrors map[string]*AggregatedError, aggregatedErr AggregatedError) {
+	existing, ok := aggregatedErrors[aggregatedErr.AggregationKey]
+	if !ok {
+		latestErrors := make([]periskop.ErrorWithContext, len(aggregatedErr.LatestErrors))
+		copy(latestErrors, aggregatedErr.LatestErrors)
+		aggregatedErr.LatestErrors = latestErrors
+		aggregatedErrors[aggregatedErr.AggregationKey] = &aggregatedErr
+		return
+	}
+
+	existing.TotalCount += aggregatedErr.TotalCount
+	if aggregatedErr.CreatedAt.Before(existing.CreatedAt) {
+		existing.CreatedAt = aggregatedErr.CreatedAt
+		existing.Severity = aggregatedErr.Severity
+	}
+	existing.LatestErrors = append(existing.LatestErrors, aggregatedErr.LatestErrors...)
+	sort.SliceStable(existing.LatestErrors, func(i, j int) bool {
+		return existing.LatestErrors[i].Timestamp.Before(existing.LatestErrors[j].Timestamp)
+	})
+	if len(existing.LatestErrors) > periskop.MaxErrors {
+		existing.LatestErrors = existing.LatestErrors[len(existing.LatestErrors)-periskop.MaxErrors:]
+	}
+}
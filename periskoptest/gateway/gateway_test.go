@@ -0,0 +1,197 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/periskop-dev/periskop-go"
+)
+
+func reportAndPush(t *testing.T, addr string, opts periskop.PushOptions, errs ...error) (*periskop.ErrorCollector, *periskop.ErrorExporter) {
+	c := periskop.NewErrorCollector()
+	e := periskop.NewErrorExporter(&c)
+	for _, err := range errs {
+		c.Report(periskop.ErrorReport{Err: err, ErrKey: err.Error()})
+	}
+	if err := e.PushToGatewayWithContext(context.Background(), addr, opts); err != nil {
+		t.Fatalf("error pushing exceptions: %v", err)
+	}
+	return &c, &e
+}
+
+func TestGateway_merge(t *testing.T) {
+	server, g := NewServer()
+	defer server.Close()
+
+	errTimeout := errors.New("timeout")
+	reportAndPush(t, server.URL, periskop.PushOptions{}, errTimeout, errTimeout)
+	reportAndPush(t, server.URL, periskop.PushOptions{Gzip: true}, errTimeout, errors.New("invalid"))
+
+	resp, err := http.Get(server.URL + "/-/exceptions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var p Payload
+	if err := json.NewDecoder(resp.Body).Decode(&p); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(p.AggregatedErrors) != 2 {
+		t.Fatalf("expected two aggregated errors, got %d", len(p.AggregatedErrors))
+	}
+	invalid, timeout := p.AggregatedErrors[0], p.AggregatedErrors[1]
+	if invalid.AggregationKey != "invalid" || invalid.TotalCount != 1 {
+		t.Errorf("unexpected aggregated error: %+v", invalid)
+	}
+	if timeout.AggregationKey != "timeout" || timeout.TotalCount != 3 || len(timeout.LatestErrors) != 3 {
+		t.Errorf("expected errors of both targets to be merged, got %+v", timeout)
+	}
+	if len(g.Targets()) != 2 {
+		t.Errorf("expected two targets, got %d", len(g.Targets()))
+	}
+}
+
+func TestGateway_Push(t *testing.T) {
+	server, g := NewServer()
+	defer server.Close()
+
+	// full pushes replace the errors previously pushed by the target
+	errTest := errors.New("testing")
+	c, e := reportAndPush(t, server.URL, periskop.PushOptions{}, errTest)
+	c.Report(periskop.ErrorReport{Err: errTest, ErrKey: "testing"})
+	if err := e.PushToGateway(server.URL); err != nil {
+		t.Fatal(err)
+	}
+	if count := g.Payload().AggregatedErrors[0].TotalCount; count != 2 {
+		t.Errorf("expected a total count of 2, got %d", count)
+	}
+
+	// delta pushes are added, ignoring the repeated ones
+	p := Payload{TargetUUID: uuid.New(), Delta: true, IdempotencyKey: "delta-1",
+		AggregatedErrors: []AggregatedError{{AggregationKey: "testing", TotalCount: 1}}}
+	g.Push(p)
+	g.Push(p)
+	payload := g.Payload()
+	if len(payload.AggregatedErrors) != 1 || payload.AggregatedErrors[0].TotalCount != 3 {
+		t.Errorf("expected a total count of 3, got %+v", payload.AggregatedErrors)
+	}
+	if g.Pushes() != 4 {
+		t.Errorf("expected 4 pushes, got %d", g.Pushes())
+	}
+}
+
+func TestGateway_maxErrors(t *testing.T) {
+	g := New()
+	for i := 0; i < 3; i++ {
+		c := periskop.NewErrorCollector()
+		for j := 0; j < periskop.MaxErrors; j++ {
+			c.Report(periskop.ErrorReport{Err: errors.New("testing"), ErrKey: "testing"})
+		}
+		e := periskop.NewErrorExporter(&c)
+		data, err := e.Export()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var p Payload
+		if err := json.Unmarshal([]byte(data), &p); err != nil {
+			t.Fatal(err)
+		}
+		g.Push(p)
+	}
+
+	aggregatedErr := g.Payload().AggregatedErrors[0]
+	if aggregatedErr.TotalCount != 3*periskop.MaxErrors || len(aggregatedErr.LatestErrors) != periskop.MaxErrors {
+		t.Errorf("expected %d errors and the latest %d, got %d and %d", 3*periskop.MaxErrors, periskop.MaxErrors,
+			aggregatedErr.TotalCount, len(aggregatedErr.LatestErrors))
+	}
+}
+
+func TestGateway_SetVerifier(t *testing.T) {
+	server, g := NewServer()
+	defer server.Close()
+	key := periskop.SigningKey{ID: "1", Secret: []byte("secret")}
+	g.SetVerifier(periskop.NewVerifier(key))
+
+	c := periskop.NewErrorCollector()
+	c.ReportError(errors.New("testing"))
+	e := periskop.NewErrorExporter(&c)
+	err := e.PushToGateway(server.URL)
+	if pushErr, ok := err.(*periskop.PushError); !ok || pushErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected unsigned push to be rejected, got %v", err)
+	}
+
+	e.SetSigner(periskop.NewSigner(key))
+	if err := e.PushToGatewayWithContext(context.Background(), server.URL, periskop.PushOptions{Gzip: true}); err != nil {
+		t.Errorf("error pushing exceptions: %v", err)
+	}
+	if len(g.Payload().AggregatedErrors) != 1 {
+		t.Errorf("expected one aggregated error")
+	}
+}
+
+func TestGateway_SetDown(t *testing.T) {
+	primary, primaryGateway := NewServer()
+	defer primary.Close()
+	secondary, secondaryGateway := NewServer()
+	defer secondary.Close()
+
+	c := periskop.NewErrorCollector()
+	c.ReportError(errors.New("testing"))
+	e := periskop.NewErrorExporter(&c)
+	pool := periskop.NewGatewayPool(periskop.FailoverPriority, primary.URL, secondary.URL)
+	opts := periskop.PushOptions{Delta: true}
+
+	primaryGateway.SetDown(true)
+	addr, err := e.PushToGateways(context.Background(), pool, opts)
+	if err != nil || addr != secondary.URL {
+		t.Errorf("expected push to the secondary gateway, got %s, %v", addr, err)
+	}
+	if len(primaryGateway.Payload().AggregatedErrors) != 0 || len(secondaryGateway.Payload().AggregatedErrors) != 1 {
+		t.Errorf("expected errors only in the secondary gateway")
+	}
+
+	secondaryGateway.SetDown(true)
+	c.ReportError(errors.New("testing"))
+	if _, err := e.PushToGateways(context.Background(), pool, opts); err == nil {
+		t.Errorf("expected push to fail while both gateways are down")
+	}
+}
+
+func TestGateway_maxIdempotencyKeys(t *testing.T) {
+	g := New()
+	id := uuid.New()
+	for i := 0; i <= MaxIdempotencyKeys; i++ {
+		g.Push(Payload{TargetUUID: id, Delta: true, IdempotencyKey: fmt.Sprintf("delta-%d", i),
+			AggregatedErrors: []AggregatedError{{AggregationKey: "testing", TotalCount: 1}}})
+	}
+	if n := len(g.targets[id].idempotencyKeys); n != MaxIdempotencyKeys {
+		t.Errorf("expected %d idempotency keys, got %d", MaxIdempotencyKeys, n)
+	}
+	if g.targets[id].seenKeys["delta-0"] || !g.targets[id].seenKeys["delta-1"] {
+		t.Errorf("expected only the oldest idempotency key to be removed")
+	}
+}
+
+func TestGateway_SetTargetTTL(t *testing.T) {
+	g := New()
+	g.SetTargetTTL(time.Hour)
+	old, current := uuid.New(), uuid.New()
+	g.Push(Payload{TargetUUID: old, AggregatedErrors: []AggregatedError{{AggregationKey: "old", TotalCount: 1}}})
+	g.Push(Payload{TargetUUID: current, AggregatedErrors: []AggregatedError{{AggregationKey: "current", TotalCount: 1}}})
+	g.targets[old].lastPush = time.Now().Add(-2 * time.Hour)
+
+	payload := g.Payload()
+	if len(payload.AggregatedErrors) != 1 || payload.AggregatedErrors[0].AggregationKey != "current" {
+		t.Errorf("expected the errors of the expired target to be removed, got %+v", payload.AggregatedErrors)
+	}
+	if _, ok := g.Targets()[old]; ok {
+		t.Errorf("expected the expired target to be removed")
+	}
+}
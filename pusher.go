@@ -0,0 +1,152 @@
+package periskop
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// DefaultPushInterval is the default interval between the pushes of a Pusher
+	DefaultPushInterval = 10 * time.Second
+	// DefaultFinalPushTimeout is the default timeout of the final push made when a signal is received
+	DefaultFinalPushTimeout = 5 * time.Second
+)
+
+// PusherOptions configures a Pusher
+type PusherOptions struct {
+	// Interval between pushes, defaults to DefaultPushInterval
+	Interval time.Duration
+	// PushOptions configures every push
+	PushOptions PushOptions
+	// HandleSignals makes a final push when the process receives SIGTERM or SIGINT. The signal is
+	// raised again after the push, so the process is terminated as it would without the Pusher.
+	// Applications handling these signals with signal.Notify receive the signal twice, so they should
+	// call Stop from their own handler instead.
+	HandleSignals bool
+	// FinalPushTimeout bounds the final push made when a signal is received, including its retries,
+	// defaults to DefaultFinalPushTimeout
+	FinalPushTimeout time.Duration
+}
+
+// Pusher pushes the errors of a collector to a pushgateway from a background goroutine. Pushes are
+// skipped when no error was collected since the last successful push.
+type Pusher struct {
+	exporter *ErrorExporter
+	addr     string
+	opts     PusherOptions
+
+	// pushMux serializes the pushes
+	pushMux       sync.Mutex
+	pushedVersion uint64
+	pushed        bool
+
+	cancel   func()
+	done     chan struct{}
+	stopOnce sync.Once
+	signals  chan os.Signal
+}
+
+// NewPusher creates a Pusher for the pushgateway specified by `addr` and starts pushing
+func NewPusher(exporter *ErrorExporter, addr string, opts PusherOptions) *Pusher {
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultPushInterval
+	}
+	if opts.FinalPushTimeout <= 0 {
+		opts.FinalPushTimeout = DefaultFinalPushTimeout
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &Pusher{
+		exporter: exporter,
+		addr:     addr,
+		opts:     opts,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+	if opts.HandleSignals {
+		p.signals = make(chan os.Signal, 1)
+		signal.Notify(p.signals, syscall.SIGTERM, os.Interrupt)
+	}
+	go p.run(ctx)
+	return p
+}
+
+func (p *Pusher) run(ctx context.Context) {
+	defer close(p.done)
+	ticker := time.NewTicker(p.opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := p.pushIfChanged(ctx); err != nil && ctx.Err() == nil {
+				fmt.Printf("error pushing Periskop errors: %s\n", err)
+			}
+		case sig := <-p.signals:
+			p.handleSignal(sig)
+			return
+		}
+	}
+}
+
+// handleSignal makes a final push, bounded by FinalPushTimeout, and raises the signal again
+func (p *Pusher) handleSignal(sig os.Signal) {
+	if p.stop() {
+		ctx, cancel := context.WithTimeout(context.Background(), p.opts.FinalPushTimeout)
+		defer cancel()
+		if err := p.pushIfChanged(ctx); err != nil {
+			fmt.Printf("error pushing Periskop errors: %s\n", err)
+		}
+	}
+	if process, err := os.FindProcess(os.Getpid()); err == nil {
+		_ = process.Signal(sig)
+	}
+}
+
+// Push pushes the collected errors right away, if any error was collected since the last push
+func (p *Pusher) Push(ctx context.Context) error {
+	return p.pushIfChanged(ctx)
+}
+
+// Stop stops the background pushes and makes a final synchronous push, which can be bounded by `ctx`
+func (p *Pusher) Stop(ctx context.Context) error {
+	first := p.stop()
+	<-p.done
+	if !first {
+		return nil
+	}
+	return p.pushIfChanged(ctx)
+}
+
+// stop stops the background goroutine, returning true the first time it's called
+func (p *Pusher) stop() bool {
+	first := false
+	p.stopOnce.Do(func() {
+		first = true
+		if p.signals != nil {
+			signal.Stop(p.signals)
+		}
+		p.cancel()
+	})
+	return first
+}
+
+func (p *Pusher) pushIfChanged(ctx context.Context) error {
+	p.pushMux.Lock()
+	defer p.pushMux.Unlock()
+	version := p.exporter.collector.getVersion()
+	if p.pushed && version == p.pushedVersion {
+		return nil
+	}
+	if err := p.exporter.PushToGatewayWithContext(ctx, p.addr, p.opts.PushOptions); err != nil {
+		return err
+	}
+	p.pushed = true
+	p.pushedVersion = version
+	return nil
+}
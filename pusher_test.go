@@ -0,0 +1,128 @@
+package periskop
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"runtime"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestPusher_interval(t *testing.T) {
+	gateway := newFakeGateway()
+	defer gateway.Close()
+
+	c := NewErrorCollector()
+	e := NewErrorExporter(&c)
+	c.ReportError(errors.New("testing"))
+	p := NewPusher(&e, gateway.URL, PusherOptions{Interval: 10 * time.Millisecond})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(gateway.pushed()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	// nothing changed since the first push, so no more pushes are made
+	time.Sleep(50 * time.Millisecond)
+	if n := len(gateway.pushed()); n != 1 {
+		t.Errorf("expected one push, got %d", n)
+	}
+
+	c.ReportError(errors.New("testing"))
+	if err := p.Stop(context.Background()); err != nil {
+		t.Errorf("error stopping pusher: %v", err)
+	}
+	if n := len(gateway.pushed()); n != 2 {
+		t.Errorf("expected a final push, got %d pushes", n)
+	}
+	if err := p.Stop(context.Background()); err != nil {
+		t.Errorf("error stopping pusher twice: %v", err)
+	}
+	if n := len(gateway.pushed()); n != 2 {
+		t.Errorf("expected a single final push, got %d pushes", n)
+	}
+}
+
+func TestPusher_Stop(t *testing.T) {
+	gateway := newFakeGateway()
+	defer gateway.Close()
+
+	c := NewErrorCollector()
+	e := NewErrorExporter(&c)
+	p := NewPusher(&e, gateway.URL, PusherOptions{Interval: time.Hour})
+	c.ReportError(errors.New("testing"))
+	if err := p.Stop(context.Background()); err != nil {
+		t.Errorf("error stopping pusher: %v", err)
+	}
+	if n := len(gateway.pushed()); n != 1 {
+		t.Errorf("expected a final push, got %d pushes", n)
+	}
+}
+
+func TestPusher_HandleSignals(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("signals can't be sent on windows")
+	}
+	if addr := os.Getenv("PERISKOP_PUSHER_GATEWAY"); addr != "" {
+		c := NewErrorCollector()
+		e := NewErrorExporter(&c)
+		timeout, _ := time.ParseDuration(os.Getenv("PERISKOP_PUSHER_TIMEOUT"))
+		NewPusher(&e, addr, PusherOptions{Interval: time.Hour, HandleSignals: true, FinalPushTimeout: timeout,
+			PushOptions: PushOptions{MaxRetries: 10}})
+		c.ReportError(errors.New("testing"))
+		process, err := os.FindProcess(os.Getpid())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := process.Signal(syscall.SIGTERM); err != nil {
+			t.Fatal(err)
+		}
+		select {}
+	}
+
+	gateway := newFakeGateway()
+	defer gateway.Close()
+
+	cmd := exec.Command(os.Args[0], "-test.run=TestPusher_HandleSignals")
+	cmd.Env = append(os.Environ(), "PERISKOP_PUSHER_GATEWAY="+gateway.URL)
+	err := cmd.Run()
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok {
+		t.Fatalf("expected process to be terminated, got %v", err)
+	}
+	if status, ok := exitErr.Sys().(syscall.WaitStatus); !ok || status.Signal() != syscall.SIGTERM {
+		t.Errorf("expected process to be terminated by SIGTERM, got %v", err)
+	}
+	if n := len(gateway.pushed()); n != 1 {
+		t.Errorf("expected a final push, got %d pushes", n)
+	}
+}
+
+func TestPusher_FinalPushTimeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("signals can't be sent on windows")
+	}
+	release := make(chan struct{})
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer gateway.Close()
+	defer close(release)
+
+	start := time.Now()
+	cmd := exec.Command(os.Args[0], "-test.run=TestPusher_HandleSignals")
+	cmd.Env = append(os.Environ(), "PERISKOP_PUSHER_GATEWAY="+gateway.URL, "PERISKOP_PUSHER_TIMEOUT=100ms")
+	err := cmd.Run()
+	if exitErr, ok := err.(*exec.ExitError); !ok {
+		t.Errorf("expected process to be terminated, got %v", err)
+	} else if status, ok := exitErr.Sys().(syscall.WaitStatus); !ok || status.Signal() != syscall.SIGTERM {
+		t.Errorf("expected process to be terminated by SIGTERM, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected the final push to be bounded by the timeout, took %s", elapsed)
+	}
+}
@@ -0,0 +1,71 @@
+package periskop
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/periskop-dev/periskop-go/errutils"
+)
+
+// ReportRaceOutput reports all the data races found in the output of a go program built with `-race`
+func (c *ErrorCollector) ReportRaceOutput(output string) error {
+	races, err := errutils.ParseRaceReport(output)
+	if err != nil {
+		return err
+	}
+	for _, race := range races {
+		c.ReportRace(race)
+	}
+	return nil
+}
+
+// ReportRace reports a data race as an unhandled error. Races are aggregated by the locations of
+// the two conflicting accesses, regardless of which one happened first.
+func (c *ErrorCollector) ReportRace(race errutils.RaceReport) {
+	current := raceLocation(race.Current)
+	previous := raceLocation(race.Previous)
+	message := fmt.Sprintf("%s at %s by goroutine %d races with previous %s at %s by goroutine %d",
+		race.Current.Operation, current, race.Current.Goroutine,
+		race.Previous.Operation, previous, race.Previous.Goroutine)
+
+	stacktrace := formatStackFrames(race.Current.Frames)
+	stacktrace = append(stacktrace, fmt.Sprintf("Previous %s by goroutine %d:", race.Previous.Operation,
+		race.Previous.Goroutine))
+	stacktrace = append(stacktrace, formatStackFrames(race.Previous.Frames)...)
+	for _, g := range race.Goroutines {
+		stacktrace = append(stacktrace, fmt.Sprintf("Goroutine %d (%s) created at:", g.ID, g.State))
+		stacktrace = append(stacktrace, formatStackFrames(g.CreatedAt)...)
+	}
+
+	locations := []string{current, previous}
+	sort.Strings(locations)
+	errKey := fmt.Sprintf("data race@%s", hash(locations[0]+locations[1]))
+
+	errorInstance := NewCustomErrorInstance(message, "data race", stacktrace)
+	errWithContext := NewErrorWithContext(errorInstance, SeverityError, nil)
+	errWithContext.Unhandled = true
+	errWithContext.Mechanism = MechanismRaceDetector
+	c.addErrorWithContext(errWithContext, SeverityError, errKey)
+}
+
+// raceLocation gets the location of the top user frame of a racing access. Frames of the runtime are
+// skipped, as accesses to maps are always made by functions like `runtime.mapassign_fast64`.
+func raceLocation(access errutils.RaceAccess) string {
+	if len(access.Frames) == 0 {
+		return "unknown"
+	}
+	frame := access.Frames[0]
+	for _, f := range access.Frames {
+		if !isRuntimeFrame(f) {
+			frame = f
+			break
+		}
+	}
+	return fmt.Sprintf("%s:%d", frame.File, frame.LineNumber)
+}
+
+func isRuntimeFrame(frame errutils.StackFrame) bool {
+	return frame.Package == "runtime" || strings.HasPrefix(frame.Package, "runtime/") ||
+		strings.HasPrefix(frame.Package, "internal/")
+}
@@ -0,0 +1,127 @@
+package periskop
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/periskop-dev/periskop-go/errutils"
+)
+
+// mapRaceOutput is the output of `go run -race` for two races in the accesses to two maps
+var mapRaceOutput = `==================
+WARNING: DATA RACE
+Write at 0x00c000076060 by goroutine 6:
+  runtime.mapassign_fast64()
+      /usr/local/go/src/internal/runtime/maps/runtime_fast64.go:182 +0x0
+  main.main.func1()
+      /home/user/maprace/main.go:10 +0x3a
+
+Previous write at 0x00c000076060 by main goroutine:
+  runtime.mapassign_fast64()
+      /usr/local/go/src/internal/runtime/maps/runtime_fast64.go:182 +0x0
+  main.main()
+      /home/user/maprace/main.go:11 +0xb4
+
+Goroutine 6 (running) created at:
+  main.main()
+      /home/user/maprace/main.go:10 +0x9e
+==================
+==================
+WARNING: DATA RACE
+Write at 0x00c000076090 by goroutine 7:
+  runtime.mapassign_fast64()
+      /usr/local/go/src/internal/runtime/maps/runtime_fast64.go:182 +0x0
+  main.main.func2()
+      /home/user/maprace/main.go:12 +0x3a
+
+Previous write at 0x00c000076090 by main goroutine:
+  runtime.mapassign_fast64()
+      /usr/local/go/src/internal/runtime/maps/runtime_fast64.go:182 +0x0
+  main.main()
+      /home/user/maprace/main.go:13 +0x14f
+
+Goroutine 7 (running) created at:
+  main.main()
+      /home/user/maprace/main.go:12 +0x139
+==================
+Found 2 data race(s)
+`
+
+func newRaceReport(current, previous string) errutils.RaceReport {
+	return errutils.RaceReport{
+		Current: errutils.RaceAccess{Operation: "read", Goroutine: 9, Frames: []errutils.StackFrame{
+			{File: current, LineNumber: 10, Name: "main.func2", Package: "main"},
+		}},
+		Previous: errutils.RaceAccess{Operation: "write", Goroutine: 8, Frames: []errutils.StackFrame{
+			{File: previous, LineNumber: 10, Name: "(*S).inc", Package: "main"},
+		}},
+		Goroutines: []errutils.RaceGoroutine{{ID: 9, State: "running", CreatedAt: []errutils.StackFrame{
+			{File: current, LineNumber: 16, Name: "main", Package: "main"},
+		}}},
+	}
+}
+
+func TestRace_ReportRace(t *testing.T) {
+	c := NewErrorCollector()
+	c.ReportRace(newRaceReport("/app/a.go", "/app/b.go"))
+	// same race with the accesses happening in the opposite order
+	c.ReportRace(newRaceReport("/app/b.go", "/app/a.go"))
+
+	if len(c.aggregatedErrors) != 1 {
+		t.Fatalf("expected one element, got %d", len(c.aggregatedErrors))
+	}
+	aggregatedErr := getFirstAggregatedErr(c.aggregatedErrors)
+	if !strings.HasPrefix(aggregatedErr.AggregationKey, "data race@") || aggregatedErr.TotalCount != 2 {
+		t.Errorf("unexpected aggregated error: %s (%d)", aggregatedErr.AggregationKey, aggregatedErr.TotalCount)
+	}
+	errorWithContext := aggregatedErr.LatestErrors[0]
+	if errorWithContext.Error.Message != "read at /app/a.go:10 by goroutine 9 races with previous write at "+
+		"/app/b.go:10 by goroutine 8" {
+		t.Errorf("incorrect message, got %s", errorWithContext.Error.Message)
+	}
+	expectedStacktrace := []string{"/app/a.go:10", "Previous write by goroutine 8:", "/app/b.go:10",
+		"Goroutine 9 (running) created at:", "/app/a.go:16"}
+	if strings.Join(errorWithContext.Error.Stacktrace, "\n") != strings.Join(expectedStacktrace, "\n") {
+		t.Errorf("incorrect stacktrace, got %v", errorWithContext.Error.Stacktrace)
+	}
+	if !errorWithContext.Unhandled || errorWithContext.Mechanism != MechanismRaceDetector {
+		t.Errorf("expected an unhandled race-detector error")
+	}
+
+	c.ReportRace(newRaceReport("/app/a.go", "/app/c.go"))
+	if len(c.aggregatedErrors) != 2 {
+		t.Errorf("expected two elements, got %d", len(c.aggregatedErrors))
+	}
+}
+
+func TestRace_ReportRaceOutput(t *testing.T) {
+	c := NewErrorCollector()
+	if err := c.ReportRaceOutput("PASS\n"); err == nil {
+		t.Errorf("expected an error for output without data races")
+	}
+}
+
+func TestRace_ReportRaceOutput_maps(t *testing.T) {
+	c := NewErrorCollector()
+	if err := c.ReportRaceOutput(mapRaceOutput); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(c.aggregatedErrors) != 2 {
+		t.Fatalf("expected the races of both maps to be aggregated separately, got %d", len(c.aggregatedErrors))
+	}
+	messages := make(map[string]bool)
+	for _, aggregatedErr := range c.aggregatedErrors {
+		messages[aggregatedErr.LatestErrors[0].Error.Message] = true
+	}
+	for _, expected := range []string{
+		"write at /home/user/maprace/main.go:10 by goroutine 6 races with previous write at " +
+			"/home/user/maprace/main.go:11 by goroutine 1",
+		"write at /home/user/maprace/main.go:12 by goroutine 7 races with previous write at " +
+			"/home/user/maprace/main.go:13 by goroutine 1",
+	} {
+		if !messages[expected] {
+			t.Errorf("expected a race with message %q, got %v", expected, messages)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package periskop
+
+import (
+	"fmt"
+)
+
+// RecoverOptions configures how recovered panics are reported
+type RecoverOptions struct {
+	// Severity of the reported panic, defaults to Error
+	Severity Severity
+	// ErrKey overrides the aggregation key of the reported panic
+	ErrKey string
+	// RePanic panics again with the recovered value once it's reported
+	RePanic bool
+	// Fields are extra fields attached to the reported panic
+	Fields map[string]interface{}
+	// GoroutineDump attaches the stacks of all the goroutines to the reported panic
+	GoroutineDump bool
+	// GoroutineDumpSize limits the size in bytes of the goroutine dump, defaults to DefaultGoroutineDumpSize
+	GoroutineDumpSize int
+}
+
+// RecoverAndReport recovers from a panic and reports it to the collector. It must be called
+// directly with defer:
+//
+//	defer collector.RecoverAndReport(periskop.RecoverOptions{})
+func (c *ErrorCollector) RecoverAndReport(opts RecoverOptions) {
+	if r := recover(); r != nil {
+		c.reportPanic(r, opts)
+		if opts.RePanic {
+			panic(r)
+		}
+	}
+}
+
+// Go runs f in a new goroutine, reporting to the collector any panic produced by it
+func Go(c *ErrorCollector, f func()) {
+	go func() {
+		defer c.RecoverAndReport(RecoverOptions{})
+		f()
+	}()
+}
+
+// reportPanic reports a value recovered from a panic. Values that are not errors are reported
+// with their message formatted with %v and a class like `panic(string)`.
+func (c *ErrorCollector) reportPanic(value interface{}, opts RecoverOptions) {
+	severity := opts.Severity
+	if severity == "" {
+		severity = SeverityError
+	}
+
+	var errWithContext ErrorWithContext
+	errKey := opts.ErrKey
+	if err, ok := value.(error); ok {
+		errWithContext = newErrorWithContextFromError(err, severity, nil)
+		errKey = getErrorKey(err, errKey)
+	} else {
+		errorInstance := NewCustomErrorInstance(fmt.Sprintf("%v", value), fmt.Sprintf("panic(%T)", value),
+			getStackTrace(nil))
+		errWithContext = NewErrorWithContext(errorInstance, severity, nil)
+	}
+	errWithContext.Unhandled = true
+	errWithContext.Mechanism = MechanismGoroutineRecover
+	if opts.GoroutineDump {
+		errWithContext.Goroutines = getGoroutineDump(opts.GoroutineDumpSize)
+	}
+	for name, value := range opts.Fields {
+		errWithContext.SetField(name, value)
+	}
+	c.addErrorWithContext(errWithContext, errWithContext.Severity, errKey)
+}
@@ -0,0 +1,84 @@
+package periskop
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+type panicValue struct {
+	code int
+}
+
+func TestRecover_RecoverAndReport(t *testing.T) {
+	cases := []struct {
+		expectedClass   string
+		expectedMessage string
+		value           interface{}
+	}{
+		{"panic(string)", "boom", "boom"},
+		{"panic(int)", "42", 42},
+		{"panic(periskop.panicValue)", "{7}", panicValue{7}},
+		{"*errors.errorString", "testing", errors.New("testing")},
+	}
+	for _, tt := range cases {
+		t.Run(tt.expectedClass, func(t *testing.T) {
+			c := NewErrorCollector()
+			func() {
+				defer c.RecoverAndReport(RecoverOptions{})
+				panic(tt.value)
+			}()
+
+			if len(c.aggregatedErrors) != 1 {
+				t.Fatalf("expected one element")
+			}
+			errorWithContext := getFirstAggregatedErr(c.aggregatedErrors).LatestErrors[0]
+			if errorWithContext.Error.Class != tt.expectedClass {
+				t.Errorf("incorrect class name, got %s", errorWithContext.Error.Class)
+			}
+			if errorWithContext.Error.Message != tt.expectedMessage {
+				t.Errorf("incorrect message, got %s", errorWithContext.Error.Message)
+			}
+			if len(errorWithContext.Error.Stacktrace) == 0 {
+				t.Errorf("expected a collected stack trace")
+			}
+			if !errorWithContext.Unhandled || errorWithContext.Mechanism != MechanismGoroutineRecover {
+				t.Errorf("expected an unhandled goroutine-recover error, got unhandled=%v mechanism=%s",
+					errorWithContext.Unhandled, errorWithContext.Mechanism)
+			}
+		})
+	}
+}
+
+func TestRecover_RePanic(t *testing.T) {
+	c := NewErrorCollector()
+	defer func() {
+		if r := recover(); r != "boom" {
+			t.Errorf("expected panic to be propagated, got %v", r)
+		}
+		if len(c.aggregatedErrors) != 1 {
+			t.Errorf("expected one element")
+		}
+	}()
+	func() {
+		defer c.RecoverAndReport(RecoverOptions{RePanic: true, Severity: SeverityWarning})
+		panic("boom")
+	}()
+}
+
+func TestRecover_Go(t *testing.T) {
+	c := NewErrorCollector()
+	Go(&c, func() {
+		panic("boom")
+	})
+
+	// wait for the goroutine to report the panic
+	var p payload
+	for i := 0; i < 100 && len(p.AggregatedErrors) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+		p = c.getAggregatedErrors(errorsFilter{})
+	}
+	if len(p.AggregatedErrors) != 1 {
+		t.Errorf("expected one element")
+	}
+}
@@ -0,0 +1,66 @@
+package periskop
+
+import (
+	"runtime"
+	"sync"
+	"time"
+)
+
+// DefaultRuntimeContextInterval is the default minimum interval between two runtime snapshots
+const DefaultRuntimeContextInterval = 5 * time.Second
+
+var processStart = time.Now()
+
+// RuntimeContext is a snapshot of the state of the process when an error was reported
+type RuntimeContext struct {
+	// CapturedAt is when the snapshot was taken, as it can be shared by errors reported in the same interval
+	CapturedAt    time.Time `json:"captured_at"`
+	Goroutines    int       `json:"goroutines"`
+	HeapAlloc     uint64    `json:"heap_alloc"`
+	NumGC         uint32    `json:"num_gc"`
+	GOMAXPROCS    int       `json:"gomaxprocs"`
+	UptimeSeconds float64   `json:"uptime_seconds"`
+	// OpenFDs is the number of open file descriptors, only available on Linux
+	OpenFDs int `json:"open_fds,omitempty"`
+}
+
+// runtimeSnapshotter takes runtime snapshots at most once per interval, as reading the memory
+// stats stops the world
+type runtimeSnapshotter struct {
+	interval time.Duration
+	mux      sync.Mutex
+	last     *RuntimeContext
+}
+
+func newRuntimeSnapshotter(interval time.Duration) *runtimeSnapshotter {
+	if interval <= 0 {
+		interval = DefaultRuntimeContextInterval
+	}
+	return &runtimeSnapshotter{interval: interval}
+}
+
+// snapshot returns a copy of the last snapshot, or takes a new one if it's older than the interval
+func (s *runtimeSnapshotter) snapshot() *RuntimeContext {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	now := time.Now()
+	if s.last == nil || now.Sub(s.last.CapturedAt) >= s.interval {
+		s.last = newRuntimeContext(now)
+	}
+	snapshot := *s.last
+	return &snapshot
+}
+
+func newRuntimeContext(now time.Time) *RuntimeContext {
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+	return &RuntimeContext{
+		CapturedAt:    now.UTC(),
+		Goroutines:    runtime.NumGoroutine(),
+		HeapAlloc:     memStats.HeapAlloc,
+		NumGC:         memStats.NumGC,
+		GOMAXPROCS:    runtime.GOMAXPROCS(0),
+		UptimeSeconds: now.Sub(processStart).Seconds(),
+		OpenFDs:       countOpenFDs(),
+	}
+}
@@ -0,0 +1,23 @@
+//go:build linux
+// +build linux
+
+package periskop
+
+import (
+	"os"
+)
+
+// countOpenFDs counts the open file descriptors of the process, or returns 0 if they can't be read
+func countOpenFDs() int {
+	f, err := os.Open("/proc/self/fd")
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+	names, err := f.Readdirnames(-1)
+	if err != nil {
+		return 0
+	}
+	// the descriptor used to read the directory is not counted
+	return len(names) - 1
+}
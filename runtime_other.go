@@ -0,0 +1,8 @@
+//go:build !linux
+// +build !linux
+
+package periskop
+
+func countOpenFDs() int {
+	return 0
+}
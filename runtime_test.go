@@ -0,0 +1,64 @@
+package periskop
+
+import (
+	"encoding/json"
+	"errors"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRuntime_EnableRuntimeContext(t *testing.T) {
+	c := NewErrorCollector()
+	c.ReportError(errors.New("without runtime context"))
+	c.EnableRuntimeContext(time.Hour)
+	c.ReportError(errors.New("with runtime context"))
+	c.ReportError(errors.New("with runtime context"))
+
+	for _, aggregatedErr := range c.aggregatedErrors {
+		for _, errWithContext := range aggregatedErr.LatestErrors {
+			if errWithContext.Error.Message == "without runtime context" {
+				if errWithContext.Runtime != nil {
+					t.Errorf("expected no runtime context")
+				}
+				continue
+			}
+			if errWithContext.Runtime == nil {
+				t.Fatalf("expected runtime context")
+			}
+			if errWithContext.Runtime.Goroutines == 0 || errWithContext.Runtime.HeapAlloc == 0 {
+				t.Errorf("expected goroutines and heap alloc, got %+v", errWithContext.Runtime)
+			}
+			if errWithContext.Runtime.GOMAXPROCS != runtime.GOMAXPROCS(0) {
+				t.Errorf("incorrect GOMAXPROCS, got %d", errWithContext.Runtime.GOMAXPROCS)
+			}
+			if runtime.GOOS == "linux" && errWithContext.Runtime.OpenFDs == 0 {
+				t.Errorf("expected open file descriptors")
+			}
+		}
+	}
+
+	payload, err := json.Marshal(c.getAggregatedErrors(errorsFilter{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(payload), `"runtime":{"captured_at"`) {
+		t.Errorf("expected runtime context in the export, got %s", payload)
+	}
+}
+
+func TestRuntime_snapshot(t *testing.T) {
+	s := newRuntimeSnapshotter(time.Hour)
+	first := s.snapshot()
+	if s.snapshot().CapturedAt != first.CapturedAt {
+		t.Errorf("expected the same snapshot within the interval")
+	}
+
+	s = newRuntimeSnapshotter(time.Nanosecond)
+	first = s.snapshot()
+	time.Sleep(time.Millisecond)
+	if s.snapshot().CapturedAt == first.CapturedAt {
+		t.Errorf("expected a new snapshot after the interval")
+	}
+}
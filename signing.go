@@ -0,0 +1,179 @@
+package periskop
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// SignatureHeader is the header with the signature of exported and pushed payloads
+	SignatureHeader = "X-Periskop-Signature"
+	// DefaultSignatureMaxAge is the default maximum age of a signature accepted by a Verifier
+	DefaultSignatureMaxAge = 5 * time.Minute
+)
+
+var (
+	// ErrInvalidSignature is returned when a signature is malformed or not made with any of the keys
+	ErrInvalidSignature = errors.New("invalid periskop signature")
+	// ErrSignatureExpired is returned when the timestamp of a signature is too old or in the future
+	ErrSignatureExpired = errors.New("expired periskop signature")
+	// ErrSignatureReplayed is returned when a signature was already verified
+	ErrSignatureReplayed = errors.New("replayed periskop signature")
+)
+
+// SigningKey is a secret shared by the signer and the verifier of payloads. The ID can't contain `,` or `=`.
+type SigningKey struct {
+	ID     string
+	Secret []byte
+}
+
+// Signer signs payloads with HMAC-SHA256. The signature has the form
+// `t=<unix timestamp>,n=<random nonce>,<key id>=<hex hmac>`, with one HMAC for every key, computed over
+// `<unix timestamp>.<nonce>.<payload>`.
+type Signer struct {
+	keys []SigningKey
+}
+
+// NewSigner creates a Signer that signs with all the given keys, so keys can be rotated by adding the
+// new key to the signer before removing the old one from the verifiers
+func NewSigner(keys ...SigningKey) *Signer {
+	return &Signer{keys: keys}
+}
+
+// Sign signs `payload` with the time `t` and a new nonce, so signing the same payload twice gives
+// two different signatures
+func (s *Signer) Sign(payload []byte, t time.Time) string {
+	timestamp := strconv.FormatInt(t.Unix(), 10)
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		fmt.Printf("error generating Periskop signature nonce: %s\n", err)
+	}
+	nonce := hex.EncodeToString(b)
+	parts := []string{"t=" + timestamp, "n=" + nonce}
+	for _, key := range s.keys {
+		parts = append(parts, key.ID+"="+hex.EncodeToString(computeHMAC(key.Secret, timestamp, nonce, payload)))
+	}
+	return strings.Join(parts, ",")
+}
+
+// Verifier verifies the signatures made by a Signer. Every signature is accepted only once: the nonces
+// of the verified signatures are kept until their timestamp expires, to reject replays.
+type Verifier struct {
+	keys map[string][]byte
+	// MaxAge is the maximum difference between the signature timestamp and the current time,
+	// defaults to DefaultSignatureMaxAge
+	MaxAge time.Duration
+
+	mux sync.Mutex
+	// seen holds the nonces of the verified signatures, with the time they expire
+	seen    map[string]time.Time
+	pruneAt time.Time
+}
+
+// NewVerifier creates a Verifier accepting signatures made with any of the given keys
+func NewVerifier(keys ...SigningKey) *Verifier {
+	v := &Verifier{
+		keys:   make(map[string][]byte, len(keys)),
+		MaxAge: DefaultSignatureMaxAge,
+		seen:   make(map[string]time.Time),
+	}
+	for _, key := range keys {
+		v.keys[key.ID] = key.Secret
+	}
+	return v
+}
+
+// Verify verifies the `signature` of `payload`, returning ErrInvalidSignature, ErrSignatureExpired or
+// ErrSignatureReplayed when it's not valid
+func (v *Verifier) Verify(signature string, payload []byte) error {
+	return v.verify(signature, payload, time.Now())
+}
+
+func (v *Verifier) verify(signature string, payload []byte, now time.Time) error {
+	var timestamp, nonce string
+	var macs [][2]string
+	for _, part := range strings.Split(signature, ",") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return ErrInvalidSignature
+		}
+		switch kv[0] {
+		case "t":
+			timestamp = kv[1]
+		case "n":
+			nonce = kv[1]
+		default:
+			macs = append(macs, [2]string{kv[0], kv[1]})
+		}
+	}
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || nonce == "" {
+		return ErrInvalidSignature
+	}
+
+	valid := false
+	for _, mac := range macs {
+		secret, ok := v.keys[mac[0]]
+		if !ok {
+			continue
+		}
+		expected, err := hex.DecodeString(mac[1])
+		if err == nil && hmac.Equal(expected, computeHMAC(secret, timestamp, nonce, payload)) {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return ErrInvalidSignature
+	}
+
+	maxAge := v.MaxAge
+	if maxAge <= 0 {
+		maxAge = DefaultSignatureMaxAge
+	}
+	signedAt := time.Unix(unix, 0)
+	if age := now.Sub(signedAt); age > maxAge || age < -maxAge {
+		return fmt.Errorf("%w: signed %s ago", ErrSignatureExpired, age.Round(time.Second))
+	}
+	return v.checkReplay(nonce, signedAt.Add(maxAge), now)
+}
+
+// checkReplay records the nonce of a valid signature until it expires, failing if it was already recorded
+func (v *Verifier) checkReplay(nonce string, expiresAt time.Time, now time.Time) error {
+	v.mux.Lock()
+	defer v.mux.Unlock()
+	if v.seen == nil {
+		v.seen = make(map[string]time.Time)
+	}
+	if now.After(v.pruneAt) {
+		for n, t := range v.seen {
+			if now.After(t) {
+				delete(v.seen, n)
+			}
+		}
+		v.pruneAt = now.Add(time.Minute)
+	}
+	if t, ok := v.seen[nonce]; ok && !now.After(t) {
+		return ErrSignatureReplayed
+	}
+	v.seen[nonce] = expiresAt
+	return nil
+}
+
+func computeHMAC(secret []byte, timestamp string, nonce string, payload []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write([]byte(nonce))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,104 @@
+package periskop
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	oldKey = SigningKey{ID: "2023", Secret: []byte("old secret")}
+	newKey = SigningKey{ID: "2024", Secret: []byte("new secret")}
+)
+
+func TestSigning_Verify(t *testing.T) {
+	payload := []byte(`{"aggregated_errors":[]}`)
+	now := time.Now()
+	cases := []struct {
+		name      string
+		signature string
+		payload   []byte
+		verifier  *Verifier
+		expected  error
+	}{
+		{"valid", NewSigner(newKey).Sign(payload, now), payload, NewVerifier(newKey), nil},
+		{"rotated signer", NewSigner(oldKey, newKey).Sign(payload, now), payload, NewVerifier(newKey), nil},
+		{"rotated verifier", NewSigner(oldKey).Sign(payload, now), payload, NewVerifier(oldKey, newKey), nil},
+		{"unknown key", NewSigner(oldKey).Sign(payload, now), payload, NewVerifier(newKey), ErrInvalidSignature},
+		{"modified payload", NewSigner(newKey).Sign(payload, now), []byte(`{}`), NewVerifier(newKey), ErrInvalidSignature},
+		{"expired", NewSigner(newKey).Sign(payload, now.Add(-time.Hour)), payload, NewVerifier(newKey), ErrSignatureExpired},
+		{"future", NewSigner(newKey).Sign(payload, now.Add(time.Hour)), payload, NewVerifier(newKey), ErrSignatureExpired},
+		{"malformed", "2024", payload, NewVerifier(newKey), ErrInvalidSignature},
+		{"missing", "", payload, NewVerifier(newKey), ErrInvalidSignature},
+		{"missing nonce", "t=" + strconv.FormatInt(now.Unix(), 10) + ",2024=00", payload, NewVerifier(newKey), ErrInvalidSignature},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.verifier.verify(tt.signature, tt.payload, now)
+			if !errors.Is(err, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestSigning_replay(t *testing.T) {
+	payload := []byte(`{"aggregated_errors":[]}`)
+	now := time.Now()
+	signer, verifier := NewSigner(newKey), NewVerifier(newKey)
+	signature := signer.Sign(payload, now)
+	if err := verifier.verify(signature, payload, now); err != nil {
+		t.Fatalf("invalid signature: %v", err)
+	}
+	if err := verifier.verify(signature, payload, now.Add(time.Minute)); !errors.Is(err, ErrSignatureReplayed) {
+		t.Errorf("expected %v, got %v", ErrSignatureReplayed, err)
+	}
+	// signing the same payload again gives a new signature
+	if err := verifier.verify(signer.Sign(payload, now), payload, now); err != nil {
+		t.Errorf("invalid signature: %v", err)
+	}
+
+	// the nonces are removed once their signature expires
+	verifier.verify(signer.Sign(payload, now.Add(time.Hour)), payload, now.Add(time.Hour))
+	if len(verifier.seen) != 1 {
+		t.Errorf("expected expired nonces to be removed, got %d", len(verifier.seen))
+	}
+}
+
+func TestSigning_handlerAndPush(t *testing.T) {
+	verifier := NewVerifier(newKey)
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("error reading push: %v", err)
+		}
+		if err := verifier.Verify(r.Header.Get(SignatureHeader), body); err != nil {
+			t.Errorf("invalid push signature: %v", err)
+		}
+	}))
+	defer gateway.Close()
+
+	c := NewErrorCollector()
+	c.ReportError(errors.New("testing"))
+	e := NewErrorExporter(&c)
+	e.SetSigner(NewSigner(newKey))
+	if err := e.PushToGatewayWithContext(context.Background(), gateway.URL, PushOptions{}); err != nil {
+		t.Errorf("error pushing exceptions: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	NewHandler(e).ServeHTTP(rec, httptest.NewRequest("GET", "/-/exceptions", nil))
+	signature := rec.Header().Get(SignatureHeader)
+	if !strings.HasPrefix(signature, "t=") {
+		t.Errorf("expected a signature, got %s", signature)
+	}
+	if err := verifier.Verify(signature, rec.Body.Bytes()); err != nil {
+		t.Errorf("invalid handler signature: %v", err)
+	}
+}
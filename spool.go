@@ -0,0 +1,215 @@
+package periskop
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultSpoolMaxSize is the default maximum size in bytes of the pushes kept in a spool
+	DefaultSpoolMaxSize int64 = 10 << 20
+	// DefaultSpoolMaxAge is the default maximum age of the pushes kept in a spool
+	DefaultSpoolMaxAge = 24 * time.Hour
+	spoolExt           = ".json"
+	spoolLock          = ".lock"
+	// spoolFull marks the names of full pushes, followed by the UUID of their target
+	spoolFull = "-full-"
+)
+
+// spoolMux serializes the access to spools from the same process, while lockSpool does it between processes
+var spoolMux sync.Mutex
+
+// spoolEntry is a push kept in a spool
+type spoolEntry struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// pushOrSpool pushes `data` after the pushes left in the spool, if it's enabled. When the gateway is
+// unreachable, `data` is kept in the spool to be pushed later, by this or another process. Pushes
+// rejected by the gateway (4xx responses other than 429) are not spooled, as they would be rejected again.
+// It returns whether `data` was spooled.
+func pushOrSpool(ctx context.Context, send sendFunc, data []byte, idempotencyKey string, opts PushOptions) (bool, error) {
+	if opts.SpoolDir == "" {
+		return false, send(ctx, data, idempotencyKey)
+	}
+
+	spoolMux.Lock()
+	defer spoolMux.Unlock()
+	unlock, err := lockSpool(ctx, opts.SpoolDir)
+	if err != nil {
+		fmt.Printf("error locking Periskop spool: %s\n", err)
+		return false, send(ctx, data, idempotencyKey)
+	}
+	defer unlock()
+
+	err = drainSpool(ctx, send, opts)
+	if err == nil {
+		err = send(ctx, data, idempotencyKey)
+	}
+	if err == nil || !failover(err) {
+		return false, err
+	}
+	if spoolErr := spoolPush(opts, data, idempotencyKey == ""); spoolErr != nil {
+		fmt.Printf("error spooling Periskop errors: %s\n", spoolErr)
+		return false, err
+	}
+	return true, err
+}
+
+// drainSpool pushes the pushes left in the spool, from oldest to newest, removing them once pushed or
+// rejected by the gateway
+func drainSpool(ctx context.Context, send sendFunc, opts PushOptions) error {
+	entries, err := readSpool(opts)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		data, err := ioutil.ReadFile(entry.path)
+		if err != nil {
+			return err
+		}
+		// the idempotency key of delta pushes is in the payload
+		var p struct {
+			IdempotencyKey string `json:"idempotency_key"`
+		}
+		if err := json.Unmarshal(data, &p); err != nil {
+			fmt.Printf("error reading spooled Periskop errors %s: %s\n", entry.path, err)
+			removeSpoolEntry(entry)
+			continue
+		}
+		if err := send(ctx, data, p.IdempotencyKey); err != nil {
+			if failover(err) {
+				return err
+			}
+			fmt.Printf("error pushing spooled Periskop errors %s: %s\n", entry.path, err)
+		}
+		removeSpoolEntry(entry)
+	}
+	return nil
+}
+
+// spoolPush writes `data` atomically in the spool, and removes the oldest pushes over the limits.
+// A full push replaces the full pushes of the same target left in the spool, as pushing them after
+// it would roll back the errors of the target in the gateway.
+func spoolPush(opts PushOptions, data []byte, full bool) error {
+	if maxSize, _ := spoolLimits(opts); int64(len(data)) > maxSize {
+		return fmt.Errorf("push of %d bytes is larger than the spool (%d bytes)", len(data), maxSize)
+	}
+	// names are sorted by the time they were spooled
+	spooledAt := time.Now().UnixNano()
+	name := fmt.Sprintf("%020d-%s%s", spooledAt, uuid.New(), spoolExt)
+	var replaced []string
+	if full {
+		var p struct {
+			TargetUUID uuid.UUID `json:"target_uuid"`
+		}
+		if err := json.Unmarshal(data, &p); err != nil {
+			return err
+		}
+		suffix := spoolFull + p.TargetUUID.String() + spoolExt
+		name = fmt.Sprintf("%020d%s", spooledAt, suffix)
+		var err error
+		if replaced, err = filepath.Glob(filepath.Join(opts.SpoolDir, "*"+suffix)); err != nil {
+			return err
+		}
+	}
+
+	if err := os.MkdirAll(opts.SpoolDir, 0755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(opts.SpoolDir, ".tmp-")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(opts.SpoolDir, name)); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	for _, path := range replaced {
+		removeSpoolEntry(spoolEntry{path: path})
+	}
+	_, err = readSpool(opts)
+	return err
+}
+
+// spoolLimits gets the maximum size and age of the pushes kept in the spool
+func spoolLimits(opts PushOptions) (int64, time.Duration) {
+	maxSize := opts.SpoolMaxSize
+	if maxSize <= 0 {
+		maxSize = DefaultSpoolMaxSize
+	}
+	maxAge := opts.SpoolMaxAge
+	if maxAge <= 0 {
+		maxAge = DefaultSpoolMaxAge
+	}
+	return maxSize, maxAge
+}
+
+// readSpool gets the pushes in the spool from oldest to newest, removing the ones over the limits
+func readSpool(opts PushOptions) ([]spoolEntry, error) {
+	maxSize, maxAge := spoolLimits(opts)
+
+	files, err := ioutil.ReadDir(opts.SpoolDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []spoolEntry
+	for _, file := range files {
+		if file.IsDir() || strings.HasPrefix(file.Name(), ".") || !strings.HasSuffix(file.Name(), spoolExt) {
+			continue
+		}
+		entries = append(entries, spoolEntry{
+			path:    filepath.Join(opts.SpoolDir, file.Name()),
+			size:    file.Size(),
+			modTime: file.ModTime(),
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].path < entries[j].path })
+
+	// keep the newest pushes within the limits
+	var size int64
+	keep := len(entries)
+	for i := len(entries) - 1; i >= 0; i-- {
+		size += entries[i].size
+		if size > maxSize || time.Since(entries[i].modTime) > maxAge {
+			break
+		}
+		keep = i
+	}
+	for _, entry := range entries[:keep] {
+		removeSpoolEntry(entry)
+	}
+	return entries[keep:], nil
+}
+
+func removeSpoolEntry(entry spoolEntry) {
+	if err := os.Remove(entry.path); err != nil && !os.IsNotExist(err) {
+		fmt.Printf("error removing spooled Periskop errors %s: %s\n", entry.path, err)
+	}
+}
@@ -0,0 +1,18 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package periskop
+
+import (
+	"context"
+	"os"
+)
+
+// lockSpool only creates the spool, as file locks are not available. The access to the spool is
+// serialized within the process, but not between processes.
+func lockSpool(ctx context.Context, dir string) (func(), error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return func() {}, nil
+}
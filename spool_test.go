@@ -0,0 +1,228 @@
+package periskop
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func spoolDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "periskop-spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return filepath.Join(dir, "spool")
+}
+
+func spoolFiles(t *testing.T, dir string) int {
+	files, err := filepath.Glob(filepath.Join(dir, "*"+spoolExt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(files)
+}
+
+func TestSpool_PushToGatewayWithContext(t *testing.T) {
+	gateway := newFakeGateway()
+	defer gateway.Close()
+	gateway.setDown(true)
+	opts := PushOptions{SpoolDir: spoolDir(t)}
+
+	// a previous process fails to push its errors
+	previous := NewErrorCollector()
+	previous.ReportError(errors.New("previous"))
+	e := NewErrorExporter(&previous)
+	if err := e.PushToGatewayWithContext(context.Background(), gateway.URL, opts); err == nil {
+		t.Fatalf("expected push to fail")
+	}
+	if n := spoolFiles(t, opts.SpoolDir); n != 1 {
+		t.Fatalf("expected one spooled push, got %d", n)
+	}
+
+	gateway.setDown(false)
+	c := NewErrorCollector()
+	c.ReportError(errors.New("current"))
+	e = NewErrorExporter(&c)
+	if err := e.PushToGatewayWithContext(context.Background(), gateway.URL, opts); err != nil {
+		t.Fatalf("error pushing exceptions: %v", err)
+	}
+	payloads := gateway.pushed()
+	if len(payloads) != 2 {
+		t.Fatalf("expected two pushes, got %d", len(payloads))
+	}
+	if payloads[0].TargetUUID != previous.uuid || payloads[1].TargetUUID != c.uuid {
+		t.Errorf("expected the spooled push to be pushed first")
+	}
+	if n := spoolFiles(t, opts.SpoolDir); n != 0 {
+		t.Errorf("expected the spool to be drained, got %d pushes", n)
+	}
+}
+
+func TestSpool_Delta(t *testing.T) {
+	gateway := newFakeGateway()
+	defer gateway.Close()
+	gateway.setDown(true)
+	opts := PushOptions{SpoolDir: spoolDir(t), Delta: true}
+
+	c := NewErrorCollector()
+	e := NewErrorExporter(&c)
+	c.ReportError(errors.New("testing"))
+	if err := e.PushToGatewayWithContext(context.Background(), gateway.URL, opts); err == nil {
+		t.Fatalf("expected push to fail")
+	}
+	gateway.setDown(false)
+	c.ReportError(errors.New("testing"))
+	if err := e.PushToGatewayWithContext(context.Background(), gateway.URL, opts); err != nil {
+		t.Fatalf("error pushing exceptions: %v", err)
+	}
+
+	payloads := gateway.pushed()
+	if len(payloads) != 2 {
+		t.Fatalf("expected two pushes, got %d", len(payloads))
+	}
+	for i, p := range payloads {
+		if p.Sequence != uint64(i+1) || p.AggregatedErrors[0].TotalCount != 1 {
+			t.Errorf("expected push %d to have one new error, got %+v", p.Sequence, p)
+		}
+	}
+}
+
+func TestSpool_limits(t *testing.T) {
+	opts := PushOptions{SpoolDir: spoolDir(t), SpoolMaxSize: 10}
+	for i := 0; i < 3; i++ {
+		if err := spoolPush(opts, []byte(`{"n":1}`), false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := spoolFiles(t, opts.SpoolDir); n != 1 {
+		t.Errorf("expected only the newest push to be kept, got %d", n)
+	}
+
+	opts = PushOptions{SpoolDir: opts.SpoolDir, SpoolMaxAge: time.Minute}
+	entries, err := readSpool(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(entries[0].path, old, old); err != nil {
+		t.Fatal(err)
+	}
+	entries, err = readSpool(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 || spoolFiles(t, opts.SpoolDir) != 0 {
+		t.Errorf("expected old pushes to be removed")
+	}
+}
+
+func TestSpool_tooLarge(t *testing.T) {
+	gateway := newFakeGateway()
+	defer gateway.Close()
+	gateway.setDown(true)
+	opts := PushOptions{SpoolDir: spoolDir(t), SpoolMaxSize: 10, Delta: true}
+
+	c := NewErrorCollector()
+	e := NewErrorExporter(&c)
+	c.ReportError(errors.New("testing"))
+	if err := e.PushToGatewayWithContext(context.Background(), gateway.URL, opts); err == nil {
+		t.Fatalf("expected push to fail")
+	}
+	if n := spoolFiles(t, opts.SpoolDir); n != 0 {
+		t.Fatalf("expected a push larger than the spool to not be spooled, got %d", n)
+	}
+
+	// the push that didn't fit in the spool is kept in memory
+	gateway.setDown(false)
+	c.ReportError(errors.New("testing"))
+	if err := e.PushToGatewayWithContext(context.Background(), gateway.URL, opts); err != nil {
+		t.Fatalf("error pushing exceptions: %v", err)
+	}
+	totalCount := 0
+	for _, p := range gateway.pushed() {
+		totalCount += p.AggregatedErrors[0].TotalCount
+	}
+	if totalCount != 2 {
+		t.Errorf("expected two errors to be pushed, got %d", totalCount)
+	}
+}
+
+func TestSpool_fullReplaced(t *testing.T) {
+	gateway := newFakeGateway()
+	defer gateway.Close()
+	gateway.setDown(true)
+	opts := PushOptions{SpoolDir: spoolDir(t)}
+
+	c := NewErrorCollector()
+	e := NewErrorExporter(&c)
+	for i := 0; i < 2; i++ {
+		c.ReportError(errors.New("testing"))
+		if err := e.PushToGatewayWithContext(context.Background(), gateway.URL, opts); err == nil {
+			t.Fatalf("expected push to fail")
+		}
+	}
+	if n := spoolFiles(t, opts.SpoolDir); n != 1 {
+		t.Fatalf("expected the newest full push to replace the older one, got %d", n)
+	}
+
+	gateway.setDown(false)
+	if err := e.PushToGatewayWithContext(context.Background(), gateway.URL, opts); err != nil {
+		t.Fatalf("error pushing exceptions: %v", err)
+	}
+	for _, p := range gateway.pushed() {
+		if p.AggregatedErrors[0].TotalCount != 2 {
+			t.Errorf("expected only full pushes with two errors, got %d", p.AggregatedErrors[0].TotalCount)
+		}
+	}
+}
+
+func TestSpool_lockContext(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file locks are not available on windows")
+	}
+	dir := spoolDir(t)
+	unlock, err := lockSpool(context.Background(), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := lockSpool(ctx, dir); err != context.DeadlineExceeded {
+		t.Errorf("expected the lock to time out, got %v", err)
+	}
+}
+
+func TestSpool_rejected(t *testing.T) {
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer gateway.Close()
+	opts := PushOptions{SpoolDir: spoolDir(t)}
+
+	// a push left in the spool is dropped when the gateway rejects it
+	if err := spoolPush(opts, []byte(`{"aggregated_errors":[]}`), false); err != nil {
+		t.Fatal(err)
+	}
+	c := NewErrorCollector()
+	e := NewErrorExporter(&c)
+	for i := 0; i < 2; i++ {
+		c.ReportError(errors.New("testing"))
+		err := e.PushToGatewayWithContext(context.Background(), gateway.URL, opts)
+		if pushErr, ok := err.(*PushError); !ok || pushErr.StatusCode != http.StatusBadRequest {
+			t.Errorf("expected a push error, got %v", err)
+		}
+		if n := spoolFiles(t, opts.SpoolDir); n != 0 {
+			t.Errorf("expected rejected pushes to not be spooled, got %d", n)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package periskop
+
+import (
+	"os"
+	"runtime"
+	"runtime/debug"
+)
+
+const (
+	// ServiceNameEnv is the environment variable with the default service name of the target
+	ServiceNameEnv = "PERISKOP_SERVICE_NAME"
+	// InstanceIDEnv is the environment variable with a stable ID of the target instance (e.g. a pod name),
+	// which unlike the target UUID doesn't change when the process restarts
+	InstanceIDEnv = "PERISKOP_INSTANCE_ID"
+)
+
+// Target holds metadata of the process exposing the errors, to attribute them to a service and release
+type Target struct {
+	ServiceName   string            `json:"service_name,omitempty"`
+	InstanceID    string            `json:"instance_id,omitempty"`
+	Hostname      string            `json:"hostname,omitempty"`
+	PID           int               `json:"pid"`
+	GoVersion     string            `json:"go_version"`
+	ModuleVersion string            `json:"module_version,omitempty"`
+	VCSRevision   string            `json:"vcs_revision,omitempty"`
+	Labels        map[string]string `json:"labels,omitempty"`
+}
+
+// newTarget gets the metadata of the current process from the environment and its build info
+func newTarget() Target {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = ""
+	}
+	target := Target{
+		ServiceName: os.Getenv(ServiceNameEnv),
+		InstanceID:  os.Getenv(InstanceIDEnv),
+		Hostname:    hostname,
+		PID:         os.Getpid(),
+		GoVersion:   runtime.Version(),
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if info.Main.Version != "(devel)" {
+			target.ModuleVersion = info.Main.Version
+		}
+		target.VCSRevision = vcsRevision(info)
+	}
+	return target
+}
+
+// copy copies the target so it can be exported while labels are being set
+func (t Target) copy() Target {
+	if t.Labels != nil {
+		labels := make(map[string]string, len(t.Labels))
+		for name, value := range t.Labels {
+			labels[name] = value
+		}
+		t.Labels = labels
+	}
+	return t
+}
+
+// SetServiceName sets the name of the service exposing the errors, overriding PERISKOP_SERVICE_NAME
+func (c *ErrorCollector) SetServiceName(name string) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.target.ServiceName = name
+}
+
+// SetInstanceID sets a stable ID of the instance exposing the errors, overriding PERISKOP_INSTANCE_ID
+func (c *ErrorCollector) SetInstanceID(id string) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.target.InstanceID = id
+}
+
+// SetLabel adds a custom label (e.g. region or environment) to the metadata of the target
+func (c *ErrorCollector) SetLabel(name, value string) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if c.target.Labels == nil {
+		c.target.Labels = make(map[string]string)
+	}
+	c.target.Labels[name] = value
+}
@@ -0,0 +1,26 @@
+//go:build go1.18
+// +build go1.18
+
+package periskop
+
+import (
+	"runtime/debug"
+)
+
+// vcsRevision gets the VCS revision stamped in the binary, marking it as dirty if there were local changes
+func vcsRevision(info *debug.BuildInfo) string {
+	var revision string
+	var modified bool
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.modified":
+			modified = setting.Value == "true"
+		}
+	}
+	if revision != "" && modified {
+		revision += "-dirty"
+	}
+	return revision
+}
@@ -0,0 +1,13 @@
+//go:build !go1.18
+// +build !go1.18
+
+package periskop
+
+import (
+	"runtime/debug"
+)
+
+// vcsRevision is not available, as VCS info is stamped in binaries since Go 1.18
+func vcsRevision(info *debug.BuildInfo) string {
+	return ""
+}
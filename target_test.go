@@ -0,0 +1,48 @@
+package periskop
+
+import (
+	"os"
+	"runtime"
+	"testing"
+)
+
+func TestTarget_newTarget(t *testing.T) {
+	os.Setenv(ServiceNameEnv, "checkout")
+	os.Setenv(InstanceIDEnv, "checkout-7d9f-1")
+	defer os.Unsetenv(ServiceNameEnv)
+	defer os.Unsetenv(InstanceIDEnv)
+
+	target := newTarget()
+	if target.ServiceName != "checkout" {
+		t.Errorf("incorrect service name, got %s", target.ServiceName)
+	}
+	if target.InstanceID != "checkout-7d9f-1" {
+		t.Errorf("incorrect instance id, got %s", target.InstanceID)
+	}
+	if target.PID != os.Getpid() {
+		t.Errorf("incorrect pid, got %d", target.PID)
+	}
+	if target.GoVersion != runtime.Version() {
+		t.Errorf("incorrect go version, got %s", target.GoVersion)
+	}
+}
+
+func TestTarget_setters(t *testing.T) {
+	c := NewErrorCollector()
+	c.SetServiceName("payments")
+	c.SetInstanceID("payments-0")
+	c.SetLabel("region", "eu-west-1")
+
+	p := c.getAggregatedErrors(errorsFilter{})
+	if p.Target.ServiceName != "payments" || p.Target.InstanceID != "payments-0" {
+		t.Errorf("incorrect target, got %+v", p.Target)
+	}
+
+	c.SetLabel("region", "us-east-1")
+	if p.Target.Labels["region"] != "eu-west-1" {
+		t.Errorf("expected exported labels not to change")
+	}
+	if c.getAggregatedErrors(errorsFilter{}).Target.Labels["region"] != "us-east-1" {
+		t.Errorf("expected label to be updated")
+	}
+}
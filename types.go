@@ -7,22 +7,41 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/periskop-dev/periskop-go/errutils"
 )
 
 // Severity is the definition of different severities
 type Severity string
 
 const (
-	SeverityInfo    Severity = "info"
-	SeverityWarning Severity = "warning"
-	SeverityError   Severity = "error"
-	MaxTraces       int      = 4
-	MaxErrors       int      = 10
+	SeverityInfo     Severity = "info"
+	SeverityWarning  Severity = "warning"
+	SeverityError    Severity = "error"
+	SeverityCritical Severity = "critical"
+	MaxTraces        int      = 4
+	MaxErrors        int      = 10
+)
+
+// Mechanism is the entry point that created a report
+type Mechanism string
+
+const (
+	MechanismManual           Mechanism = "manual"
+	MechanismGoroutineRecover Mechanism = "goroutine-recover"
+	MechanismGroup            Mechanism = "group"
+	MechanismPanicParse       Mechanism = "panic-parse"
+	MechanismRaceDetector     Mechanism = "race-detector"
 )
 
 type payload struct {
 	AggregatedErrors []aggregatedError `json:"aggregated_errors"`
 	TargetUUID       uuid.UUID         `json:"target_uuid"`
+	Target           Target            `json:"target"`
+	// Delta is true when the payload only has the errors collected since the previous push, in which
+	// case TotalCount is the increment since that push
+	Delta          bool   `json:"delta,omitempty"`
+	Sequence       uint64 `json:"sequence,omitempty"`
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
 }
 
 type aggregatedError struct {
@@ -31,6 +50,8 @@ type aggregatedError struct {
 	Severity       Severity           `json:"severity"`
 	LatestErrors   []ErrorWithContext `json:"latest_errors"`
 	CreatedAt      time.Time          `json:"created_at"`
+	// class is the class of the first error, which doesn't change like the class of the latest errors
+	class string
 }
 
 func newAggregatedError(aggregationKey string, severity Severity) aggregatedError {
@@ -48,6 +69,9 @@ func (e *aggregatedError) addError(errWithContext ErrorWithContext) {
 		e.LatestErrors = e.LatestErrors[1:]
 	}
 	e.LatestErrors = append(e.LatestErrors, errWithContext)
+	if e.TotalCount == 0 {
+		e.class = errWithContext.Error.Class
+	}
 	e.TotalCount++
 }
 
@@ -60,13 +84,29 @@ type HTTPContext struct {
 }
 
 type ErrorWithContext struct {
-	Error       ErrorInstance `json:"error"`
-	UUID        uuid.UUID     `json:"uuid"`
-	Timestamp   time.Time     `json:"timestamp"`
-	Severity    Severity      `json:"severity"`
-	HTTPContext *HTTPContext  `json:"http_context"`
+	Error       ErrorInstance          `json:"error"`
+	UUID        uuid.UUID              `json:"uuid"`
+	Timestamp   time.Time              `json:"timestamp"`
+	Severity    Severity               `json:"severity"`
+	HTTPContext *HTTPContext           `json:"http_context"`
+	Fields      map[string]interface{} `json:"fields,omitempty"`
+	// Unhandled is true for errors that were not handled by the application, like panics
+	Unhandled bool `json:"unhandled,omitempty"`
+	// Mechanism is the entry point that created the report, MechanismManual when not set
+	Mechanism Mechanism `json:"mechanism,omitempty"`
+	// Goroutines holds the stacks of all the goroutines when the error happened, if captured
+	Goroutines []GoroutineGroup `json:"goroutines,omitempty"`
+	// Runtime holds the state of the process when the error happened, if enabled in the collector
+	Runtime *RuntimeContext `json:"runtime,omitempty"`
+	// CorrelationID is shared by the errors reported together from a single multi-error
+	CorrelationID string `json:"correlation_id,omitempty"`
+	// ErrKey overrides the aggregation key of the error when not empty
+	ErrKey string `json:"-"`
+
+	err error
 }
 
+// NewErrorWithContext creates a new ErrorWithContext, reported as a handled error with the manual mechanism
 func NewErrorWithContext(errInstance ErrorInstance, severity Severity, httpCtx *HTTPContext) ErrorWithContext {
 	return ErrorWithContext{
 		Error:       errInstance,
@@ -74,7 +114,21 @@ func NewErrorWithContext(errInstance ErrorInstance, severity Severity, httpCtx *
 		Timestamp:   time.Now().UTC(),
 		Severity:    severity,
 		HTTPContext: httpCtx,
+		Mechanism:   MechanismManual,
+	}
+}
+
+// Err returns the Go error the report was created from, or nil if it was created manually
+func (e *ErrorWithContext) Err() error {
+	return e.err
+}
+
+// SetField attaches an extra field to the reported error
+func (e *ErrorWithContext) SetField(name string, value interface{}) {
+	if e.Fields == nil {
+		e.Fields = make(map[string]interface{})
 	}
+	e.Fields[name] = value
 }
 
 type ErrorInstance struct {
@@ -82,6 +136,10 @@ type ErrorInstance struct {
 	Message    string         `json:"message"`
 	Stacktrace []string       `json:"stacktrace"`
 	Cause      *ErrorInstance `json:"cause"`
+	// Causes holds the errors wrapped by a multi-error, when reported with MultiErrorCauses
+	Causes []ErrorInstance `json:"causes,omitempty"`
+	// Type is the Go type of the reported error, when it's different from Class
+	Type string `json:"type,omitempty"`
 }
 
 func newErrorInstance(err error, errType string, stacktrace []string) ErrorInstance {
@@ -101,6 +159,16 @@ func NewCustomErrorInstance(errMsg string, errType string, stacktrace []string)
 	}
 }
 
+// NewErrorInstanceFromStack allows to create a custom error instance with the stacktrace parsed from the
+// output of runtime/debug.Stack(), or from a traceback (see errutils.ParseStack)
+func NewErrorInstanceFromStack(errMsg string, errType string, stack string) (ErrorInstance, error) {
+	frames, err := errutils.ParseStack(stack)
+	if err != nil {
+		return ErrorInstance{}, err
+	}
+	return NewCustomErrorInstance(errMsg, errType, formatStackFrames(frames)), nil
+}
+
 // aggregationKey generates a hash for errorWithContext using the last MaxTraces
 func (e *ErrorWithContext) aggregationKey() string {
 	stacktraceHead := e.Error.Stacktrace
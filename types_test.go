@@ -52,3 +52,22 @@ func TestTypes_addError(t *testing.T) {
 		t.Errorf("expected %v latest errors", MaxErrors)
 	}
 }
+
+func TestTypes_NewErrorInstanceFromStack(t *testing.T) {
+	stack := "goroutine 1 [running]:\nruntime/debug.Stack()\n\t/go/src/runtime/debug/stack.go:26 +0x5e\n" +
+		"main.main()\n\t/tmp/main.go:8 +0x1d\n"
+	errorInstance, err := NewErrorInstanceFromStack("testing", "logged_error", stack)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errorInstance.Class != "logged_error" || errorInstance.Message != "testing" {
+		t.Errorf("unexpected error instance: %+v", errorInstance)
+	}
+	if len(errorInstance.Stacktrace) != 2 || errorInstance.Stacktrace[1] != "/tmp/main.go:8" {
+		t.Errorf("unexpected stacktrace: %v", errorInstance.Stacktrace)
+	}
+
+	if _, err := NewErrorInstanceFromStack("testing", "logged_error", "not a stack"); err == nil {
+		t.Errorf("expected an error for an invalid stack")
+	}
+}